@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	"k8s.io/klog/v2"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogging configures klog to log in the given format.
+// The level is the klog verbosity, messages logged with a higher verbosity are dropped.
+func setupLogging(format string, level int) error {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("v", strconv.Itoa(level)); err != nil {
+		return err
+	}
+	if format == logFormatJSON {
+		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+			// logr maps verbosity V(n) to the slog level -n
+			Level: slog.Level(-level),
+		})
+		klog.SetLogger(logr.FromSlogHandler(handler))
+	}
+	return nil
+}
@@ -3,14 +3,15 @@ package main
 
 import (
 	"context"
-	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
 	"github.com/projectsyn/steward/pkg/agent"
+	"github.com/projectsyn/steward/pkg/agent/facts"
 	"github.com/projectsyn/steward/pkg/images"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -20,34 +21,35 @@ import (
 var Version = "unreleased"
 
 func main() {
-	klog.InitFlags(nil)
-	flag.Set("logtostderr", "true")
-	flag.Set("v", "3")
-	klog.Info("Starting SYN cluster agent 🕵️")
-	klog.Infof("Version %s", Version)
 	app := kingpin.New("steward", "Steward makes your Kubernetes cluster SYN managed. 🎉")
 	app.DefaultEnvars()
 	app.Version(Version)
-	ctx, cancel := context.WithCancel(context.Background())
-	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGTERM)
-	go receiveSignal(signalCh, cancel)
+	agent := agent.Agent{Version: Version}
 
-	agent := agent.Agent{}
-	app.Action(func(*kingpin.ParseContext) error {
-		return agent.Run(ctx)
-	})
+	logFormat := app.Flag("log-format", "Log format, text or json.").Default(logFormatText).Enum(logFormatText, logFormatJSON)
+	logLevel := app.Flag("log-level", "Log verbosity, higher levels log more details.").Default("3").Int()
 
 	app.Flag("api", "API URL to connect to").Required().URLVar(&agent.APIURL)
 	app.Flag("token", "Token to authenticate to the API").Required().StringVar(&agent.Token)
 	app.Flag("cluster-id", "ID of own cluster").Required().StringVar(&agent.ClusterID)
-	app.Flag("cloud", "Cloud type this cluster is running on").StringVar(&agent.CloudType)
-	app.Flag("region", "Cloud region this cluster is running in").StringVar(&agent.CloudRegion)
+	app.Flag("cloud", "Cloud type this cluster is running on, detected from the nodes if empty").StringVar(&agent.CloudType)
+	app.Flag("region", "Cloud region this cluster is running in, detected from the nodes if empty").StringVar(&agent.CloudRegion)
 	app.Flag("distribution", "Kubernetes distribution this cluster is running").StringVar(&agent.Distribution)
 	app.Flag("namespace", "Namespace in which steward is running").Default("syn").StringVar(&agent.Namespace)
 	app.Flag("operator-namespace", "Namespace in which the ArgoCD operator will be running").Default("syn-argocd-operator").StringVar(&agent.OperatorNamespace)
 	app.Flag("argo-image", "Image to be used for the Argo CD deployments").Default(images.DefaultArgoCDImage).StringVar(&agent.ArgoCDImage)
 	app.Flag("redis-image", "Image to be used for the Argo CD Redis deployment").Default(images.DefaultRedisImage).StringVar(&agent.RedisImage)
+	app.
+		Flag(
+			"argocd-upgrade",
+			"Roll out changed Argo CD and Redis images and newer Argo CD CRDs to an already bootstrapped Argo CD. Without it the running images and CRDs are kept.").
+		BoolVar(&agent.ArgoCDUpgrade)
+	app.
+		Flag(
+			"argocd-upgrade-timeout",
+			"Maximum time to wait for the new Argo CD images to roll out.").
+		Default("5m").
+		DurationVar(&agent.ArgoCDUpgradeTimeout)
 	app.
 		Flag(
 			"additional-facts-config-map",
@@ -60,6 +62,11 @@ func main() {
 			"Config map holding metadata for additional ArgoCD root apps and app projects.").
 		Default("additional-root-apps").
 		StringVar(&agent.AdditionalRootAppsConfigMap)
+	app.
+		Flag(
+			"prune-additional-root-apps",
+			"Delete the root apps and projects of teams which are no longer listed. Without it they're only deleted if annotated with steward.syn.tools/confirm-removal=true.").
+		BoolVar(&agent.PruneAdditionalRootApps)
 	app.
 		Flag(
 			"ocp-oauth-route-namespace",
@@ -72,16 +79,188 @@ func main() {
 			"Name of the OpenShift OAuth route").
 		Default("oauth-openshift").
 		StringVar(&agent.OCPOAuthRouteName)
+	app.
+		Flag(
+			"resync-interval",
+			"Interval of the full sync with the Lieutenant API, which also reconciles Argo CD. Changes of the fact sources are synced immediately.").
+		Default("5m").
+		DurationVar(&agent.ResyncInterval)
+	app.
+		Flag(
+			"force-sync-interval",
+			"Interval after which the cluster object is updated even if the reported facts didn't change.").
+		Default("1h").
+		DurationVar(&agent.ForceSyncInterval)
+	app.
+		Flag(
+			"initial-sync-jitter",
+			"Maximum random delay of the first sync after startup.").
+		Default("10s").
+		DurationVar(&agent.InitialSyncJitter)
+	app.
+		Flag(
+			"retry-backoff",
+			"Delay of the first retry after a failed sync, doubled for every subsequent failure.").
+		Default("10s").
+		DurationVar(&agent.RetryBackoff)
+	app.
+		Flag(
+			"max-retry-backoff",
+			"Maximum delay between retries after failed syncs.").
+		Default("5m").
+		DurationVar(&agent.MaxRetryBackoff)
+	app.
+		Flag(
+			"shutdown-grace-period",
+			"Time a running sync gets to finish on shutdown before it's aborted. Should be shorter than the pod's termination grace period.").
+		Default("20s").
+		DurationVar(&agent.ShutdownGracePeriod)
+	app.
+		Flag(
+			"metrics-bind-address",
+			"Address the Prometheus metrics endpoint listens on, \"0\" disables it.").
+		Default(":8080").
+		StringVar(&agent.MetricsAddress)
+	app.
+		Flag(
+			"health-probe-bind-address",
+			"Address the liveness (/healthz) and readiness (/readyz) probes listen on, \"0\" disables them.").
+		Default(":8081").
+		StringVar(&agent.HealthProbeAddress)
+	app.
+		Flag(
+			"liveness-threshold",
+			"Steward isn't live if the sync loop is stuck for longer than this. Raised to the timeout of a sync if that's longer.").
+		Default("10m").
+		DurationVar(&agent.LivenessThreshold)
+	app.
+		Flag(
+			"readiness-threshold",
+			"Steward isn't ready if the last successful sync with Lieutenant or Argo CD reconcile is older than this.").
+		Default("15m").
+		DurationVar(&agent.ReadinessThreshold)
+	app.
+		Flag(
+			"leader-elect",
+			"Use leader election so only one of multiple steward replicas syncs at a time.").
+		BoolVar(&agent.LeaderElection)
+	app.
+		Flag(
+			"leader-election-lease-name",
+			"Name of the lease used for leader election.").
+		Default("steward-leader").
+		StringVar(&agent.LeaderElectionLeaseName)
+	app.
+		Flag(
+			"leader-election-namespace",
+			"Namespace of the lease used for leader election, defaults to the steward namespace.").
+		StringVar(&agent.LeaderElectionNamespace)
+	app.
+		Flag(
+			"leader-election-lease-duration",
+			"Duration standby replicas wait before taking over a lease which wasn't renewed.").
+		Default("15s").
+		DurationVar(&agent.LeaseDuration)
+	app.
+		Flag(
+			"leader-election-renew-deadline",
+			"Duration the leader retries renewing the lease before it gives up leadership.").
+		Default("10s").
+		DurationVar(&agent.LeaseRenewDeadline)
+	app.
+		Flag(
+			"leader-election-retry-period",
+			"Interval between attempts to acquire or renew the lease.").
+		Default("2s").
+		DurationVar(&agent.LeaseRetryPeriod)
+	app.
+		Flag(
+			"deployment-name",
+			"Name of steward's deployment, Kubernetes events are recorded on it.").
+		Default("steward").
+		StringVar(&agent.DeploymentName)
+	app.
+		Flag(
+			"config-name",
+			"Name of the StewardConfig in the steward namespace which overrides the settings from the flags, empty disables it.").
+		Default("steward").
+		StringVar(&agent.ConfigName)
+	app.
+		Flag(
+			"status-config-map",
+			"Config map steward writes the outcome of the last sync to, empty disables it.").
+		Default("steward-status").
+		StringVar(&agent.StatusConfigMap)
+	app.
+		Flag(
+			"fact-provider",
+			"Fact provider to run, can be repeated. All providers are run if not set. Available providers: "+strings.Join(facts.Providers(), ", ")).
+		StringsVar(&agent.FactProviders)
+	app.
+		Flag(
+			"disable-fact-provider",
+			"Fact provider to skip, can be repeated.").
+		StringsVar(&agent.DisabledFactProviders)
+	app.
+		Flag(
+			"fact-provider-timeout",
+			"Maximum time a single fact provider may take to collect its facts.").
+		Default(facts.DefaultProviderTimeout.String()).
+		DurationVar(&agent.FactProviderTimeout)
+	app.
+		Flag(
+			"capacity-facts-by-role",
+			"Add the capacity and allocatable resources per node role to the capacity fact.").
+		BoolVar(&agent.CapacityFactsByRole)
+	app.
+		Flag(
+			"crd-facts",
+			"Add the names of all installed CRDs to the customResourceDefinitions fact.").
+		BoolVar(&agent.CRDFacts)
 
-	kingpin.MustParse(app.Parse(os.Args[1:]))
-}
+	if _, err := app.Parse(os.Args[1:]); err != nil {
+		klog.ErrorS(err, "Invalid arguments")
+		exit(exitInvalidArguments)
+	}
+	if err := setupLogging(*logFormat, *logLevel); err != nil {
+		klog.ErrorS(err, "Invalid log configuration")
+		exit(exitInvalidArguments)
+	}
+	klog.InfoS("Starting SYN cluster agent 🕵️", "version", Version)
 
-func receiveSignal(signalCh chan os.Signal, cancel context.CancelFunc) {
-	for {
-		select {
-		case sig := <-signalCh:
-			klog.V(3).Infof("Received signal '%v'", sig)
-			cancel()
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	handleSignals(cancel)
+	if err := agent.Run(ctx); err != nil {
+		klog.ErrorS(err, "Steward failed")
+		exit(exitError)
 	}
+	klog.InfoS("Steward stopped")
+	exit(exitOK)
+}
+
+// Exit codes of steward, a second signal during shutdown exits with 128 + the signal number
+const (
+	exitOK               = 0
+	exitError            = 1
+	exitInvalidArguments = 2
+)
+
+// handleSignals cancels the context on the first SIGINT or SIGTERM to start the graceful shutdown.
+// A second signal exits immediately.
+func handleSignals(cancel context.CancelFunc) {
+	signalCh := make(chan os.Signal, 2)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signalCh
+		klog.InfoS("Received signal, shutting down", "signal", sig.String())
+		cancel()
+		sig = <-signalCh
+		klog.InfoS("Received signal during shutdown, exiting immediately", "signal", sig.String())
+		exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
+
+func exit(code int) {
+	klog.Flush()
+	os.Exit(code)
 }
@@ -1,10 +1,9 @@
 package agent
 
 import (
-	"bytes"
+	"cmp"
 	"context"
-	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"net/url"
 	"os"
@@ -12,19 +11,32 @@ import (
 
 	"github.com/oapi-codegen/oapi-codegen/v2/pkg/securityprovider"
 	"github.com/projectsyn/lieutenant-api/pkg/api"
+	apixv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
 	"github.com/projectsyn/steward/pkg/agent/facts"
 	"github.com/projectsyn/steward/pkg/argocd"
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/metrics"
+	"github.com/projectsyn/steward/pkg/stewardconfig"
+)
+
+const (
+	defaultResyncInterval    = 5 * time.Minute
+	defaultForceSyncInterval = time.Hour
+	// factChangeDebounce delays the sync after a fact source changed, so a burst of changes results in a single sync
+	factChangeDebounce = 10 * time.Second
 )
 
 // Agent configures the cluster agent
 type Agent struct {
 	APIURL            *url.URL
 	Token             string
+	Version           string
 	ClusterID         string
 	CloudType         string
 	CloudRegion       string
@@ -33,21 +45,104 @@ type Agent struct {
 	OperatorNamespace string
 	ArgoCDImage       string
 	RedisImage        string
+	// Roll out changed images to an already bootstrapped Argo CD and wait at most the upgrade timeout for it
+	ArgoCDUpgrade        bool
+	ArgoCDUpgradeTimeout time.Duration
 	// The configmap containing additional facts to be added to the dynamic facts
 	AdditionalFactsConfigMap string
 
 	// The configmap containing metadata for additional root apps to deploy
 	AdditionalRootAppsConfigMap string
+	// Delete the root apps and projects of teams which are no longer listed, without it only confirmed removals are done
+	PruneAdditionalRootApps bool
 
 	// Reference to the OpenShift OAuth route to be added to the dynamic facts
 	OCPOAuthRouteNamespace string
 	OCPOAuthRouteName      string
 
+	// Fact providers to run or skip, an empty list of enabled providers runs all of them
+	FactProviders         []string
+	DisabledFactProviders []string
+	FactProviderTimeout   time.Duration
+	// Add the capacity per node role to the capacity fact
+	CapacityFactsByRole bool
+	// Add the names of all installed CRDs to the dynamic facts
+	CRDFacts bool
+
+	// Interval of the full resync, changes of the fact sources trigger a sync in between
+	ResyncInterval time.Duration
+	// Interval after which the cluster object is updated even if the reported properties didn't change
+	ForceSyncInterval time.Duration
+	// Maximum random delay of the first sync, spreads the load on Lieutenant if many agents start at once
+	InitialSyncJitter time.Duration
+	// Initial and maximum delay of the retries after a failed sync
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// Time a running sync gets to finish on shutdown before it's cancelled
+	ShutdownGracePeriod time.Duration
+
+	// Address the metrics endpoint listens on, "0" disables it
+	MetricsAddress string
+	// Address the liveness and readiness probes listen on, "0" disables them
+	HealthProbeAddress string
+	// The agent isn't live if the sync loop is stuck for longer than the liveness threshold
+	LivenessThreshold time.Duration
+	// The agent isn't ready if the last successful sync or Argo CD reconcile is older than the readiness threshold
+	ReadinessThreshold time.Duration
+
+	// Only run the syncs while holding the lease, allows running multiple replicas
+	LeaderElection bool
+	// Name and namespace of the lease, the namespace defaults to the steward namespace
+	LeaderElectionLeaseName string
+	LeaderElectionNamespace string
+	// Duration standby replicas wait before taking over a lease which wasn't renewed
+	LeaseDuration time.Duration
+	// Duration the leader retries renewing the lease before it gives up leadership
+	LeaseRenewDeadline time.Duration
+	// Interval between attempts to acquire or renew the lease
+	LeaseRetryPeriod time.Duration
+
+	// Name of steward's deployment, events are recorded on it
+	DeploymentName string
+	// The configmap the outcome of the last sync is written to, empty disables it
+	StatusConfigMap string
+
+	// Name of the StewardConfig in the steward namespace which overrides the settings from the flags, empty disables it
+	ConfigName string
+
 	facts facts.FactCollector
+
+	// Settings from the flags, the StewardConfig is applied on top of them
+	flags stewardconfig.Settings
+	// Generation of the StewardConfig last evaluated
+	configGeneration int64
+	// Root apps from the StewardConfig, created in addition to the ones from the additional root apps ConfigMap
+	additionalRootApps []argocd.RootApp
+	// Tuning of the Argo CD components from the StewardConfig
+	argoCDTuning argocd.Tuning
+
+	// Hash of the cluster properties last sent to Lieutenant and the time they were sent
+	lastSyncedHash string
+	lastFullSync   time.Time
+
+	health *health
+	status syncStatus
 }
 
 // Run starts the cluster agent
 func (a *Agent) Run(ctx context.Context) error {
+	ctx = klog.NewContext(ctx, klog.FromContext(ctx).WithValues("cluster", a.ClusterID))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	if err := serveHTTP(ctx, "metrics", a.MetricsAddress, mux); err != nil {
+		return err
+	}
+	a.health = newHealth(a.LivenessThreshold, a.ReadinessThreshold)
+	a.health.setMaxSyncDuration(a.syncTimeout())
+	if err := serveHTTP(ctx, "health probes", a.HealthProbeAddress, a.health.handler()); err != nil {
+		return err
+	}
+
 	bearerToken, _ := securityprovider.NewSecurityProviderBearerToken(a.Token)
 	apiClient, err := api.NewClient(a.APIURL.String(), api.WithRequestEditorFn(bearerToken.Intercept))
 	if err != nil {
@@ -68,6 +163,8 @@ func (a *Agent) Run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	stopEvents := events.Setup(ctx, client, a.Namespace, a.DeploymentName)
+	defer stopEvents()
 
 	a.facts = facts.FactCollector{
 		Client: client,
@@ -77,34 +174,78 @@ func (a *Agent) Run(ctx context.Context) error {
 
 		AdditionalFactsConfigMapNamespace: a.Namespace,
 		AdditionalFactsConfigMapName:      a.AdditionalFactsConfigMap,
-	}
 
-	ticker := time.NewTicker(1 * time.Minute)
+		ArgoCDNamespace: a.Namespace,
+		StewardVersion:  a.Version,
+
+		CloudType:    a.CloudType,
+		CloudRegion:  a.CloudRegion,
+		Distribution: a.Distribution,
+
+		EnabledProviders:  a.FactProviders,
+		DisabledProviders: a.DisabledFactProviders,
+		ProviderTimeout:   a.FactProviderTimeout,
 
-	a.registerCluster(ctx, config, client, apiClient)
+		CapacityByRole: a.CapacityFactsByRole,
+		CollectCRDs:    a.CRDFacts,
+	}
+	if err := a.facts.Validate(); err != nil {
+		return err
+	}
 
-	for {
-		select {
-		case <-ticker.C:
-			a.registerCluster(ctx, config, client, apiClient)
-		case <-ctx.Done():
-			return nil
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	a.flags = a.settings()
+	if a.ConfigName != "" {
+		crdClient, err := apixv1client.NewForConfig(config)
+		if err != nil {
+			return err
 		}
+		if err := stewardconfig.InstallCRD(ctx, crdClient); err != nil {
+			// Not fatal, steward keeps using the flags
+			klog.FromContext(ctx).Error(err, "Unable to install the StewardConfig CRD")
+		} else if err := stewardconfig.WaitForCRD(ctx, crdClient, stewardconfig.CRDEstablishedTimeout); err != nil {
+			// Not fatal, the StewardConfig is still applied on every resync
+			klog.FromContext(ctx).Error(err, "StewardConfig CRD isn't served, not watching the StewardConfig for changes")
+		}
+	}
+	run := func(ctx, lease context.Context) {
+		changes, err := a.watchFactSources(ctx, client, dynamicClient)
+		if err != nil {
+			// Not fatal, the facts are still updated on every resync
+			klog.FromContext(ctx).Error(err, "Unable to watch fact sources")
+		}
+
+		a.runSyncLoop(ctx, lease, changes, func(ctx context.Context) error {
+			// The timeout covers the whole sync including the StewardConfig, the outcome is recorded even if it expired
+			syncCtx, cancel := context.WithTimeout(ctx, a.syncTimeout())
+			defer cancel()
+			a.reconcileConfig(syncCtx, dynamicClient)
+			err := a.registerCluster(syncCtx, config, client, apiClient)
+			a.recordSync(ctx, client, err)
+			return err
+		})
 	}
+	if !a.LeaderElection {
+		metrics.Leader.Set(1)
+		// Without leader election only the shutdown ends a sync
+		run(ctx, context.Background())
+		return nil
+	}
+	return a.runLeaderElection(ctx, client, run)
 }
 
-func (a *Agent) registerCluster(ctx context.Context, config *rest.Config, clientset *kubernetes.Clientset, apiClient *api.Client) {
-	ctx, cancel := context.WithTimeout(ctx, time.Minute)
-	defer cancel()
+func (a *Agent) registerCluster(ctx context.Context, config *rest.Config, clientset *kubernetes.Clientset, apiClient *api.Client) error {
+	ctx = klog.NewContext(ctx, klog.FromContext(ctx).WithValues("namespace", a.Namespace))
 
 	publicKey, err := argocd.CreateSSHSecret(ctx, clientset, a.Namespace)
 	if err != nil {
-		klog.Errorf("Error creating SSH secret: %v", err)
-		return
+		return fmt.Errorf("error creating SSH secret: %w", err)
 	}
 	if err := argocd.CreateArgoSecret(ctx, clientset, a.Namespace, a.Token); err != nil {
-		klog.Errorf("Error creating Argo CD secret: %v", err)
-		return
+		return fmt.Errorf("error creating Argo CD secret: %w", err)
 	}
 	patchCluster := api.ClusterProperties{
 		GitRepo: &api.GitRepo{
@@ -113,44 +254,67 @@ func (a *Agent) registerCluster(ctx context.Context, config *rest.Config, client
 	}
 	patchCluster.DynamicFacts, err = a.facts.FetchDynamicFacts(ctx)
 	if err != nil {
-		klog.Errorf("Error fetching dynamic facts: %v", err)
+		klog.FromContext(ctx).Error(err, "Error fetching dynamic facts")
 	}
 
-	setFact("cloud", a.CloudType, &patchCluster)
-	setFact("region", a.CloudRegion, &patchCluster)
+	cloudType, cloudRegion := a.CloudType, a.CloudRegion
+	if detected, ok := facts.DetectedCloud(patchCluster.DynamicFacts); ok {
+		// Only fall back to the detected values, the flags always take precedence
+		if cloudType == "" {
+			cloudType = detected.Provider
+		}
+		if cloudRegion == "" {
+			cloudRegion = detected.Region
+		}
+	}
+
+	setFact("cloud", cloudType, &patchCluster)
+	setFact("region", cloudRegion, &patchCluster)
 	setFact("distribution", a.Distribution, &patchCluster)
 
-	var buf io.ReadWriter
-	buf = new(bytes.Buffer)
-	if err := json.NewEncoder(buf).Encode(patchCluster); err != nil {
-		klog.Error(err)
-		return
+	cluster, err := a.syncCluster(ctx, apiClient, patchCluster)
+	if err != nil {
+		return err
 	}
-	resp, err := apiClient.UpdateClusterWithBody(ctx, api.ClusterIdParameter(a.ClusterID), api.ContentJSONPatch, buf)
+	a.health.synced()
+
+	a.status.ArgoCDState, err = argocd.Apply(ctx, config, a.Namespace, a.OperatorNamespace, a.ArgoCDImage, a.RedisImage, argocd.RootAppOptions{
+		ConfigMapName: a.AdditionalRootAppsConfigMap,
+		Apps:          a.additionalRootApps,
+		Prune:         a.PruneAdditionalRootApps,
+	}, cluster, argocd.UpgradeOptions{
+		Enabled: a.ArgoCDUpgrade,
+		Timeout: a.ArgoCDUpgradeTimeout,
+	}, a.argoCDTuning)
 	if err != nil {
-		klog.Error(err)
-		return
+		return err
 	}
-	defer resp.Body.Close()
-	defer io.Copy(io.Discard, resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		reason := &api.Reason{}
-		if err := json.NewDecoder(resp.Body).Decode(reason); err != nil {
-			klog.Error(err)
-			return
-		}
-		klog.Error(reason.Reason)
-		return
+	a.health.argoCDReconciled()
+	return nil
+}
+
+// syncTimeout limits a sync, both the StewardConfig reconcile and the registration.
+// It leaves enough time to wait for the components during a bootstrap or for the rollout of an upgrade.
+func (a *Agent) syncTimeout() time.Duration {
+	wait := argocd.MaxBootstrapWait
+	if a.ArgoCDUpgrade {
+		wait = max(wait, cmp.Or(a.ArgoCDUpgradeTimeout, argocd.DefaultRolloutTimeout))
 	}
-	cluster := &api.Cluster{}
-	if err := json.NewDecoder(resp.Body).Decode(cluster); err != nil {
-		klog.Error(err)
-		return
+	return time.Minute + wait
+}
+
+func (a *Agent) resyncInterval() time.Duration {
+	if a.ResyncInterval <= 0 {
+		return defaultResyncInterval
 	}
+	return a.ResyncInterval
+}
 
-	if err := argocd.Apply(ctx, config, a.Namespace, a.OperatorNamespace, a.ArgoCDImage, a.RedisImage, a.AdditionalRootAppsConfigMap, cluster); err != nil {
-		klog.Error(err)
+func (a *Agent) forceSyncInterval() time.Duration {
+	if a.ForceSyncInterval <= 0 {
+		return defaultForceSyncInterval
 	}
+	return a.ForceSyncInterval
 }
 
 func setFact(fact, value string, cluster *api.ClusterProperties) {
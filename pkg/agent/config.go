@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+
+	"go.uber.org/multierr"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/stewardconfig"
+)
+
+// reconcileConfig applies the StewardConfig on top of the settings from the flags and reports the outcome in its status.
+// An invalid configuration keeps the previous settings, the flags are used again once the StewardConfig is deleted.
+func (a *Agent) reconcileConfig(ctx context.Context, client dynamic.Interface) {
+	if a.ConfigName == "" {
+		return
+	}
+	log := klog.FromContext(ctx).WithValues("kind", stewardconfig.Kind, "name", a.ConfigName)
+	cfg, err := stewardconfig.Get(ctx, client, a.Namespace, a.ConfigName)
+	if err != nil {
+		log.Error(err, "Unable to read the steward config, keeping the previous settings")
+		return
+	}
+	if cfg == nil {
+		if a.configGeneration != 0 {
+			log.Info("Steward config removed, using the command line flags")
+			a.configGeneration = 0
+		}
+		a.applySettings(a.flags)
+		return
+	}
+
+	changed := cfg.Generation != a.configGeneration
+	a.configGeneration = cfg.Generation
+	settings := cfg.Spec.Apply(a.flags)
+	err = a.validateSettings(cfg.Spec, settings)
+	switch {
+	case err != nil && changed:
+		log.Error(err, "Invalid steward config, keeping the previous settings", "generation", cfg.Generation)
+		events.Warning(events.ReasonStewardConfigInvalid, "Invalid StewardConfig %s: %v", a.ConfigName, err)
+	case err == nil:
+		if changed {
+			log.Info("Applying steward config", "generation", cfg.Generation)
+		}
+		a.applySettings(settings)
+	}
+	if err := stewardconfig.UpdateStatus(ctx, client, cfg, err); err != nil {
+		log.Error(err, "Unable to update the steward config status")
+	}
+}
+
+// validateSettings checks the spec and the fact providers of the resulting settings
+func (a *Agent) validateSettings(spec stewardconfig.StewardConfigSpec, settings stewardconfig.Settings) error {
+	col := a.facts
+	col.EnabledProviders = settings.FactProviders
+	col.DisabledProviders = settings.DisabledProviders
+	return multierr.Append(spec.Validate(), col.Validate())
+}
+
+// settings returns the current values of the settings which can be changed with a StewardConfig
+func (a *Agent) settings() stewardconfig.Settings {
+	return stewardconfig.Settings{
+		ArgoCDImage:          a.ArgoCDImage,
+		RedisImage:           a.RedisImage,
+		AdditionalRootApps:   a.additionalRootApps,
+		PruneRootApps:        a.PruneAdditionalRootApps,
+		FactProviders:        a.FactProviders,
+		DisabledProviders:    a.DisabledFactProviders,
+		FactProviderTimeout:  a.FactProviderTimeout,
+		CapacityFactsByRole:  a.CapacityFactsByRole,
+		CRDFacts:             a.CRDFacts,
+		ResyncInterval:       a.ResyncInterval,
+		ForceSyncInterval:    a.ForceSyncInterval,
+		ArgoCDUpgrade:        a.ArgoCDUpgrade,
+		ArgoCDUpgradeTimeout: a.ArgoCDUpgradeTimeout,
+		ArgoCDTuning:         a.argoCDTuning,
+	}
+}
+
+// applySettings replaces the settings of the agent and of its fact collector
+func (a *Agent) applySettings(s stewardconfig.Settings) {
+	a.ArgoCDImage = s.ArgoCDImage
+	a.RedisImage = s.RedisImage
+	a.additionalRootApps = s.AdditionalRootApps
+	a.PruneAdditionalRootApps = s.PruneRootApps
+	a.FactProviders = s.FactProviders
+	a.DisabledFactProviders = s.DisabledProviders
+	a.FactProviderTimeout = s.FactProviderTimeout
+	a.CapacityFactsByRole = s.CapacityFactsByRole
+	a.CRDFacts = s.CRDFacts
+	a.ResyncInterval = s.ResyncInterval
+	a.ForceSyncInterval = s.ForceSyncInterval
+	a.ArgoCDUpgrade = s.ArgoCDUpgrade
+	a.ArgoCDUpgradeTimeout = s.ArgoCDUpgradeTimeout
+	a.argoCDTuning = s.ArgoCDTuning
+	a.health.setMaxSyncDuration(a.syncTimeout())
+
+	a.facts.EnabledProviders = s.FactProviders
+	a.facts.DisabledProviders = s.DisabledProviders
+	a.facts.ProviderTimeout = s.FactProviderTimeout
+	a.facts.CapacityByRole = s.CapacityFactsByRole
+	a.facts.CollectCRDs = s.CRDFacts
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/stewardconfig"
+)
+
+func TestReconcileConfig(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	events.SetRecorder(rec, nil)
+	t.Cleanup(func() { events.SetRecorder(nil, nil) })
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		stewardconfig.GVR: stewardconfig.Kind + "List",
+	})
+	configs := client.Resource(stewardconfig.GVR).Namespace("syn")
+	a := &Agent{Namespace: "syn", ConfigName: "steward", ArgoCDImage: "argocd:v1", ResyncInterval: 5 * time.Minute}
+	a.flags = a.settings()
+
+	a.reconcileConfig(t.Context(), client)
+	assert.Equal(t, "argocd:v1", a.ArgoCDImage)
+
+	_, err := configs.Create(t.Context(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": stewardconfig.Group + "/" + stewardconfig.Version,
+		"kind":       stewardconfig.Kind,
+		"metadata":   map[string]interface{}{"name": "steward", "namespace": "syn", "generation": int64(1)},
+		"spec": map[string]interface{}{
+			"images":             map[string]interface{}{"argocd": "argocd:v2"},
+			"additionalRootApps": []interface{}{"team-a"},
+			"facts":              map[string]interface{}{"disabledProviders": []interface{}{"apis"}},
+			"sync":               map[string]interface{}{"resyncInterval": "1m"},
+			"argocd":             map[string]interface{}{"server": map[string]interface{}{"replicas": int64(2)}},
+		},
+	}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	a.reconcileConfig(t.Context(), client)
+	assert.Equal(t, "argocd:v2", a.ArgoCDImage)
+	assert.Equal(t, []argocd.RootApp{{Name: "team-a"}}, a.additionalRootApps)
+	assert.Equal(t, []string{"apis"}, a.facts.DisabledProviders)
+	assert.Equal(t, time.Minute, a.resyncInterval())
+	assert.Equal(t, argocd.Tuning{Server: &argocd.ComponentTuning{Replicas: ptr.To[int32](2)}}, a.argoCDTuning)
+	assert.Empty(t, rec.Events)
+
+	_, err = configs.Update(t.Context(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": stewardconfig.Group + "/" + stewardconfig.Version,
+		"kind":       stewardconfig.Kind,
+		"metadata":   map[string]interface{}{"name": "steward", "namespace": "syn", "generation": int64(2)},
+		"spec": map[string]interface{}{
+			"images": map[string]interface{}{"argocd": "argocd:v3"},
+			"facts":  map[string]interface{}{"enabledProviders": []interface{}{"unknown"}},
+		},
+	}}, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	a.reconcileConfig(t.Context(), client)
+	assert.Equal(t, "argocd:v2", a.ArgoCDImage, "invalid config must keep the previous settings")
+	require.Len(t, rec.Events, 1)
+	assert.Contains(t, <-rec.Events, "Warning StewardConfigInvalid Invalid StewardConfig steward: unknown fact provider \"unknown\"")
+
+	// The event is only emitted once per generation
+	a.reconcileConfig(t.Context(), client)
+	assert.Empty(t, rec.Events)
+
+	require.NoError(t, configs.Delete(t.Context(), "steward", metav1.DeleteOptions{}))
+	a.reconcileConfig(t.Context(), client)
+	assert.Equal(t, "argocd:v1", a.ArgoCDImage)
+	assert.Empty(t, a.additionalRootApps)
+	assert.Empty(t, a.argoCDTuning)
+	assert.Equal(t, 5*time.Minute, a.resyncInterval())
+}
+
+func TestReconcileConfigDisabled(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": stewardconfig.Group + "/" + stewardconfig.Version,
+		"kind":       stewardconfig.Kind,
+		"metadata":   map[string]interface{}{"name": "steward", "namespace": "syn", "generation": int64(1)},
+		"spec": map[string]interface{}{
+			"images": map[string]interface{}{"argocd": "argocd:v2"},
+		},
+	}})
+	a := &Agent{Namespace: "syn", ArgoCDImage: "argocd:v1"}
+	a.flags = a.settings()
+
+	a.reconcileConfig(t.Context(), client)
+	assert.Equal(t, "argocd:v1", a.ArgoCDImage)
+}
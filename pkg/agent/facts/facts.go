@@ -4,79 +4,173 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
 
 	"github.com/projectsyn/lieutenant-api/pkg/api"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/metrics"
 )
 
+// FactCollector gathers the dynamic facts of the cluster by running the registered fact providers.
 type FactCollector struct {
-	Client *kubernetes.Clientset
+	Client kubernetes.Interface
 
 	OAuthRouteNamespace string
 	OAuthRouteName      string
 
 	AdditionalFactsConfigMapNamespace string
 	AdditionalFactsConfigMapName      string
+
+	// ArgoCDNamespace is the namespace of Argo CD, its applications and components are reported in the `argocd` fact
+	ArgoCDNamespace string
+	// StewardVersion is reported in the `stewardVersion` fact
+	StewardVersion string
+
+	// The configured cloud type, region and distribution, detected values are compared against them
+	CloudType    string
+	CloudRegion  string
+	Distribution string
+
+	// CapacityByRole adds the capacity per node role to the `capacity` fact
+	CapacityByRole bool
+	// CollectCRDs adds the names of all installed CRDs to the `customResourceDefinitions` fact
+	CollectCRDs bool
+
+	// EnabledProviders limits the fact providers to run. All providers are run if it's empty.
+	EnabledProviders []string
+	// DisabledProviders lists fact providers which are never run.
+	DisabledProviders []string
+	// ProviderTimeout limits the time each fact provider may take. Defaults to DefaultProviderTimeout.
+	ProviderTimeout time.Duration
+
+	// snapshot is shared by the providers of a single collection
+	snapshot *snapshot
 }
 
-func (col FactCollector) FetchDynamicFacts(ctx context.Context) (*api.DynamicClusterFacts, error) {
-	facts := api.DynamicClusterFacts{}
+// snapshot caches the cluster state several fact providers are based on during a single collection,
+// so it's only fetched once from the API server
+type snapshot struct {
+	nodes             memo[[]corev1.Node]
+	kubernetesVersion memo[*version.Info]
+	openshiftVersion  memo[*SemanticVersion]
+}
 
-	additionalFacts, err := col.fetchAdditionalFacts(ctx)
-	if err != nil {
-		klog.Errorf("Error fetching additional facts: %v", err)
-	}
-	for k, v := range additionalFacts {
-		facts[k] = v
-	}
+// memo holds a value once it was fetched successfully, failed fetches are retried
+type memo[T any] struct {
+	mu      sync.Mutex
+	fetched bool
+	value   T
+}
 
-	kubeVersion, err := col.fetchKubernetesVersion(ctx)
-	if err != nil {
-		klog.Errorf("Error fetching kubernetes version: %v", err)
+func (m *memo[T]) get(fetch func() (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fetched {
+		return m.value, nil
 	}
-	if kubeVersion != nil {
-		facts["kubernetesVersion"] = kubeVersion
-	}
-
-	ocpVersion, err := col.fetchOpenshiftVersion(ctx)
+	value, err := fetch()
 	if err != nil {
-		klog.Errorf("Error fetching openshift version: %v", err)
+		return value, err
 	}
-	if ocpVersion != nil {
-		facts["openshiftVersion"] = ocpVersion
+	m.value, m.fetched = value, true
+	return value, nil
+}
+
+// cache returns the snapshot of the current collection, or an empty one outside of a collection
+func (col FactCollector) cache() *snapshot {
+	if col.snapshot == nil {
+		return &snapshot{}
 	}
+	return col.snapshot
+}
 
-	ocpOAuthRoute, err := col.fetchOpenshiftOAuthRoute(ctx)
-	if err != nil {
-		klog.Errorf("Error fetching openshift oauth route: %v", err)
+// Validate checks that all enabled and disabled fact providers are registered.
+func (col FactCollector) Validate() error {
+	known := Providers()
+	for _, name := range append(slices.Clone(col.EnabledProviders), col.DisabledProviders...) {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown fact provider %q, available providers: %s", name, strings.Join(known, ", "))
+		}
 	}
-	if ocpOAuthRoute != "" {
-		facts["openshiftOAuthRoute"] = ocpOAuthRoute
+	return nil
+}
+
+func (col FactCollector) FetchDynamicFacts(ctx context.Context) (*api.DynamicClusterFacts, error) {
+	facts := api.DynamicClusterFacts{}
+	col.snapshot = &snapshot{}
+
+	for _, p := range providers {
+		if !col.providerEnabled(p.Name()) {
+			continue
+		}
+		start := time.Now()
+		providerFacts, err := col.collect(ctx, p)
+		metrics.FactCollectionDuration.WithLabelValues(p.Name()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.FactCollectionErrors.WithLabelValues(p.Name()).Inc()
+			klog.FromContext(ctx).Error(err, "Error fetching facts", "provider", p.Name())
+		}
+		for k, v := range providerFacts {
+			facts[k] = v
+		}
 	}
 
 	return &facts, nil
 }
 
-func (col FactCollector) fetchKubernetesVersion(ctx context.Context) (*version.Info, error) {
-	// We are not using `col.client.ServerVersion()` to get context support
-	body, err := col.Client.RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
-	if err != nil {
-		return nil, err
+func (col FactCollector) providerEnabled(name string) bool {
+	if slices.Contains(col.DisabledProviders, name) {
+		return false
 	}
-	var info version.Info
-	err = json.Unmarshal(body, &info)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse the kubernetes version: %w", err)
-	}
-	info, err = processKubernetesVersion(info)
-	if err != nil {
-		return nil, fmt.Errorf("unexpected kubernetes version: %w", err)
+	return len(col.EnabledProviders) == 0 || slices.Contains(col.EnabledProviders, name)
+}
+
+// collect runs a single fact provider with its own timeout.
+// A panicking provider is turned into an error so it can't take down the other providers.
+func (col FactCollector) collect(ctx context.Context, p FactProvider) (facts api.DynamicClusterFacts, err error) {
+	timeout := col.ProviderTimeout
+	if timeout <= 0 {
+		timeout = DefaultProviderTimeout
 	}
-	return &info, nil
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			facts, err = nil, fmt.Errorf("fact provider panicked: %v", r)
+		}
+	}()
+	return p.Collect(ctx, col)
+}
+
+// fetchKubernetesVersion returns the version of the API server, it's only fetched once per collection
+func (col FactCollector) fetchKubernetesVersion(ctx context.Context) (*version.Info, error) {
+	return col.cache().kubernetesVersion.get(func() (*version.Info, error) {
+		// We are not using `col.client.ServerVersion()` to get context support
+		body, err := col.Client.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+		if err != nil {
+			return nil, err
+		}
+		var info version.Info
+		err = json.Unmarshal(body, &info)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the kubernetes version: %w", err)
+		}
+		info, err = processKubernetesVersion(info)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected kubernetes version: %w", err)
+		}
+		return &info, nil
+	})
 }
 
 func processKubernetesVersion(v version.Info) (version.Info, error) {
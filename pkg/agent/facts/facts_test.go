@@ -1,11 +1,20 @@
 package facts
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcessKubernetesVersion(t *testing.T) {
@@ -51,3 +60,59 @@ func TestProcessKubernetesVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchDynamicFactsListsNodesOnce(t *testing.T) {
+	client := fake.NewClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: map[string]string{"topology.kubernetes.io/region": "eu-central-1"}},
+		Spec:       corev1.NodeSpec{ProviderID: "aws:///eu-central-1a/i-123"},
+	})
+	col := FactCollector{Client: client, EnabledProviders: []string{"capacity", "cloud"}}
+
+	facts, err := col.FetchDynamicFacts(t.Context())
+	require.NoError(t, err)
+	assert.Contains(t, *facts, "capacity")
+	assert.Contains(t, *facts, "detectedCloud")
+
+	lists := 0
+	for _, action := range client.Actions() {
+		if action.Matches("list", "nodes") {
+			lists++
+		}
+	}
+	assert.Equal(t, 1, lists)
+
+	// Every collection lists the nodes again
+	_, err = col.FetchDynamicFacts(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, client.Actions(), 2)
+}
+
+func TestFetchDynamicFactsFetchesVersionsOnce(t *testing.T) {
+	var versions, ocpVersions atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/version":
+			versions.Add(1)
+			_, _ = w.Write([]byte(`{"major": "1", "minor": "31", "gitVersion": "v1.31.2+k3s1"}`))
+		case "/api/v1/nodes":
+			_, _ = w.Write([]byte(`{"kind": "NodeList", "apiVersion": "v1", "items": []}`))
+		default:
+			if r.URL.Path == "/apis/config.openshift.io/v1/clusterversions/version" {
+				ocpVersions.Add(1)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "NotFound", "code": 404}`))
+		}
+	}))
+	defer srv.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	require.NoError(t, err)
+	col := FactCollector{Client: client, EnabledProviders: []string{"kubernetes-version", "openshift-version", "nodes", "distribution"}}
+
+	facts, err := col.FetchDynamicFacts(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "k3s", (*facts)["detectedDistribution"])
+	assert.Equal(t, int32(1), versions.Load())
+	assert.Equal(t, int32(1), ocpVersions.Load())
+}
@@ -0,0 +1,93 @@
+package facts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// partialObjectMetadataList requests only the metadata of the listed objects, CRD schemas can be huge
+const partialObjectMetadataList = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1"
+
+type apisProvider struct{}
+
+func (apisProvider) Name() string { return "apis" }
+
+func (apisProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	versions, err := col.fetchAPIVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	facts := api.DynamicClusterFacts{"apiVersions": versions}
+
+	if col.CollectCRDs {
+		crds, err := col.fetchCRDNames(ctx)
+		if err != nil {
+			// Still report the API versions
+			return facts, err
+		}
+		facts["customResourceDefinitions"] = crds
+	}
+	return facts, nil
+}
+
+// fetchAPIVersions returns the sorted list of all group versions served by the API server, for example `apps/v1`.
+// The core API versions are reported without group, for example `v1`.
+func (col FactCollector) fetchAPIVersions(ctx context.Context) ([]string, error) {
+	// We are not using `col.Client.Discovery().ServerGroups()` to get context support
+	body, err := col.Client.Discovery().RESTClient().Get().AbsPath("/api").Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the core API versions: %w", err)
+	}
+	var core metav1.APIVersions
+	if err := json.Unmarshal(body, &core); err != nil {
+		return nil, fmt.Errorf("unable to parse the core API versions: %w", err)
+	}
+
+	body, err = col.Client.Discovery().RESTClient().Get().AbsPath("/apis").Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the API groups: %w", err)
+	}
+	var groups metav1.APIGroupList
+	if err := json.Unmarshal(body, &groups); err != nil {
+		return nil, fmt.Errorf("unable to parse the API groups: %w", err)
+	}
+
+	return processAPIVersions(core, groups), nil
+}
+
+func processAPIVersions(core metav1.APIVersions, groups metav1.APIGroupList) []string {
+	versions := slices.Clone(core.Versions)
+	for _, g := range groups.Groups {
+		for _, v := range g.Versions {
+			versions = append(versions, v.GroupVersion)
+		}
+	}
+	slices.Sort(versions)
+	return slices.Compact(versions)
+}
+
+// fetchCRDNames returns the sorted names of all installed CRDs, for example `applications.argoproj.io`.
+func (col FactCollector) fetchCRDNames(ctx context.Context) ([]string, error) {
+	body, err := col.Client.Discovery().RESTClient().Get().
+		AbsPath("/apis/apiextensions.k8s.io/v1/customresourcedefinitions").
+		SetHeader("Accept", partialObjectMetadataList).
+		Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the CRDs: %w", err)
+	}
+	var crds metav1.PartialObjectMetadataList
+	if err := json.Unmarshal(body, &crds); err != nil {
+		return nil, fmt.Errorf("unable to parse the CRDs: %w", err)
+	}
+	names := make([]string, 0, len(crds.Items))
+	for _, crd := range crds.Items {
+		names = append(names, crd.Name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
@@ -0,0 +1,43 @@
+package facts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProcessAPIVersions(t *testing.T) {
+	core := metav1.APIVersions{Versions: []string{"v1"}}
+	groups := metav1.APIGroupList{Groups: []metav1.APIGroup{
+		{
+			Name: "monitoring.coreos.com",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: "monitoring.coreos.com/v1", Version: "v1"},
+				{GroupVersion: "monitoring.coreos.com/v1alpha1", Version: "v1alpha1"},
+			},
+		},
+		{
+			Name: "apps",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: "apps/v1", Version: "v1"},
+			},
+		},
+		{
+			Name: "cert-manager.io",
+			Versions: []metav1.GroupVersionForDiscovery{
+				{GroupVersion: "cert-manager.io/v1", Version: "v1"},
+			},
+		},
+	}}
+
+	assert.Equal(t, []string{
+		"apps/v1",
+		"cert-manager.io/v1",
+		"monitoring.coreos.com/v1",
+		"monitoring.coreos.com/v1alpha1",
+		"v1",
+	}, processAPIVersions(core, groups))
+
+	assert.Empty(t, processAPIVersions(metav1.APIVersions{}, metav1.APIGroupList{}))
+}
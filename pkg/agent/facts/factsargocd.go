@@ -0,0 +1,186 @@
+package facts
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	argoRootAppName       = "root"
+	argoRootAppNamePrefix = "root-"
+	argoComponentSelector = "app.kubernetes.io/part-of=argocd"
+	unknownStatus         = "Unknown"
+)
+
+// ArgoCDStatus is reported as the `argocd` dynamic fact.
+// It only holds fields which change with the health of Argo CD, not on every sync,
+// so unchanged facts aren't sent to Lieutenant again.
+type ArgoCDStatus struct {
+	// Healthy is true if all root apps are synced and healthy and all components are ready
+	Healthy bool `json:"healthy"`
+	// RootApps lists the root app and the additional root apps created by steward
+	RootApps []ApplicationStatus `json:"rootApps"`
+	// Components lists the Argo CD deployments and statefulsets
+	Components []ComponentStatus `json:"components"`
+}
+
+// ApplicationStatus is the sync and health status of an Argo CD application
+type ApplicationStatus struct {
+	Name         string `json:"name"`
+	SyncStatus   string `json:"syncStatus"`
+	HealthStatus string `json:"healthStatus"`
+}
+
+// ComponentStatus is the readiness of an Argo CD deployment or statefulset
+type ComponentStatus struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Replicas int32  `json:"replicas"`
+	Ready    bool   `json:"ready"`
+}
+
+// argoApplicationList holds the fields of the Argo CD applications reported in the facts
+type argoApplicationList struct {
+	Items []struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Status   struct {
+			Sync struct {
+				Status string `json:"status"`
+			} `json:"sync"`
+			Health struct {
+				Status string `json:"status"`
+			} `json:"health"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type argoCDProvider struct{}
+
+func (argoCDProvider) Name() string { return "argocd" }
+
+func (argoCDProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	if col.ArgoCDNamespace == "" {
+		return nil, nil
+	}
+	apps, err := col.fetchArgoApplications(ctx)
+	if err != nil {
+		return nil, err
+	}
+	components, err := col.fetchArgoComponents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.DynamicClusterFacts{"argocd": summarizeArgoCD(apps, components)}, nil
+}
+
+type stewardVersionProvider struct{}
+
+func (stewardVersionProvider) Name() string { return "steward-version" }
+
+func (stewardVersionProvider) Collect(_ context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	if col.StewardVersion == "" {
+		return nil, nil
+	}
+	return api.DynamicClusterFacts{"stewardVersion": col.StewardVersion}, nil
+}
+
+func (col FactCollector) fetchArgoApplications(ctx context.Context) (argoApplicationList, error) {
+	var apps argoApplicationList
+	body, err := col.Client.Discovery().RESTClient().Get().
+		AbsPath(path.Join("/apis/argoproj.io/v1alpha1/namespaces", col.ArgoCDNamespace, "applications")).
+		Do(ctx).Raw()
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The Argo CD CRDs aren't installed yet
+			return apps, nil
+		}
+		return apps, fmt.Errorf("unable to list the Argo CD applications: %w", err)
+	}
+	if err := json.Unmarshal(body, &apps); err != nil {
+		return apps, fmt.Errorf("unable to parse the Argo CD applications: %w", err)
+	}
+	return apps, nil
+}
+
+func (col FactCollector) fetchArgoComponents(ctx context.Context) ([]ComponentStatus, error) {
+	opts := metav1.ListOptions{LabelSelector: argoComponentSelector}
+	deployments, err := col.Client.AppsV1().Deployments(col.ArgoCDNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the Argo CD deployments: %w", err)
+	}
+	statefulSets, err := col.Client.AppsV1().StatefulSets(col.ArgoCDNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the Argo CD statefulsets: %w", err)
+	}
+
+	components := []ComponentStatus{}
+	for _, d := range deployments.Items {
+		components = append(components, componentStatus(d.Name, "Deployment", d.Spec.Replicas, d.Status.ReadyReplicas))
+	}
+	for _, s := range statefulSets.Items {
+		components = append(components, componentStatus(s.Name, "StatefulSet", s.Spec.Replicas, s.Status.ReadyReplicas))
+	}
+	return components, nil
+}
+
+func componentStatus(name, kind string, replicas *int32, readyReplicas int32) ComponentStatus {
+	desired := int32(1)
+	if replicas != nil {
+		desired = *replicas
+	}
+	return ComponentStatus{
+		Name:     name,
+		Kind:     kind,
+		Replicas: desired,
+		Ready:    desired > 0 && readyReplicas >= desired,
+	}
+}
+
+func summarizeArgoCD(apps argoApplicationList, components []ComponentStatus) ArgoCDStatus {
+	status := ArgoCDStatus{
+		RootApps:   []ApplicationStatus{},
+		Components: components,
+	}
+
+	for _, app := range apps.Items {
+		if !isRootApp(app.Metadata.Name) {
+			continue
+		}
+		status.RootApps = append(status.RootApps, ApplicationStatus{
+			Name:         app.Metadata.Name,
+			SyncStatus:   cmp.Or(app.Status.Sync.Status, unknownStatus),
+			HealthStatus: cmp.Or(app.Status.Health.Status, unknownStatus),
+		})
+	}
+	slices.SortFunc(status.RootApps, func(a, b ApplicationStatus) int { return cmp.Compare(a.Name, b.Name) })
+	slices.SortFunc(status.Components, func(a, b ComponentStatus) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name))
+	})
+
+	status.Healthy = len(status.RootApps) > 0 && len(status.Components) > 0
+	for _, app := range status.RootApps {
+		if app.SyncStatus != "Synced" || app.HealthStatus != "Healthy" {
+			status.Healthy = false
+		}
+	}
+	for _, c := range status.Components {
+		if !c.Ready {
+			status.Healthy = false
+		}
+	}
+	return status
+}
+
+// isRootApp returns true for the root app and the additional root apps `root-<team>` created by steward
+func isRootApp(name string) bool {
+	return name == argoRootAppName || strings.HasPrefix(name, argoRootAppNamePrefix)
+}
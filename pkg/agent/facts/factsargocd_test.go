@@ -0,0 +1,131 @@
+package facts
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func argoApps(t *testing.T, apps string) argoApplicationList {
+	var list argoApplicationList
+	require.NoError(t, json.Unmarshal([]byte(apps), &list))
+	return list
+}
+
+func TestSummarizeArgoCD(t *testing.T) {
+	readyComponents := []ComponentStatus{
+		{Name: "argocd-server", Kind: "Deployment", Replicas: 1, Ready: true},
+		{Name: "argocd-application-controller", Kind: "StatefulSet", Replicas: 1, Ready: true},
+	}
+
+	tcs := map[string]struct {
+		apps       string
+		components []ComponentStatus
+		expected   ArgoCDStatus
+	}{
+		"healthy": {
+			apps: `{"items": [
+				{"metadata": {"name": "root"}, "status": {"sync": {"status": "Synced", "revision": "abc"}, "health": {"status": "Healthy"}}},
+				{"metadata": {"name": "root-team-a"}, "status": {"sync": {"status": "Synced"}, "health": {"status": "Healthy"}}},
+				{"metadata": {"name": "argocd"}, "status": {"sync": {"status": "OutOfSync"}, "health": {"status": "Healthy"}}}
+			]}`,
+			components: readyComponents,
+			expected: ArgoCDStatus{
+				Healthy: true,
+				RootApps: []ApplicationStatus{
+					{Name: "root", SyncStatus: "Synced", HealthStatus: "Healthy"},
+					{Name: "root-team-a", SyncStatus: "Synced", HealthStatus: "Healthy"},
+				},
+				Components: readyComponents,
+			},
+		},
+		"degraded root app": {
+			apps: `{"items": [
+				{"metadata": {"name": "root"}, "status": {"sync": {"status": "Synced"}, "health": {"status": "Degraded"}}}
+			]}`,
+			components: readyComponents,
+			expected: ArgoCDStatus{
+				RootApps: []ApplicationStatus{
+					{Name: "root", SyncStatus: "Synced", HealthStatus: "Degraded"},
+				},
+				Components: readyComponents,
+			},
+		},
+		"component not ready": {
+			apps: `{"items": [
+				{"metadata": {"name": "root"}}
+			]}`,
+			components: []ComponentStatus{
+				{Name: "argocd-server", Kind: "Deployment", Replicas: 1},
+			},
+			expected: ArgoCDStatus{
+				RootApps: []ApplicationStatus{
+					{Name: "root", SyncStatus: "Unknown", HealthStatus: "Unknown"},
+				},
+				Components: []ComponentStatus{
+					{Name: "argocd-server", Kind: "Deployment", Replicas: 1},
+				},
+			},
+		},
+		"not bootstrapped": {
+			apps:       `{}`,
+			components: []ComponentStatus{},
+			expected: ArgoCDStatus{
+				RootApps:   []ApplicationStatus{},
+				Components: []ComponentStatus{},
+			},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, summarizeArgoCD(argoApps(t, tc.apps), tc.components))
+		})
+	}
+}
+
+func TestFetchArgoComponents(t *testing.T) {
+	labels := map[string]string{"app.kubernetes.io/part-of": "argocd"}
+	client := fake.NewClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-server", Namespace: "syn", Labels: labels},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-repo-server", Namespace: "syn", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "syn"},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "argocd-application-controller", Namespace: "syn", Labels: labels},
+			Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](0)},
+		},
+	)
+	col := FactCollector{Client: client, ArgoCDNamespace: "syn"}
+
+	components, err := col.fetchArgoComponents(t.Context())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []ComponentStatus{
+		{Name: "argocd-server", Kind: "Deployment", Replicas: 1, Ready: true},
+		{Name: "argocd-repo-server", Kind: "Deployment", Replicas: 2},
+		{Name: "argocd-application-controller", Kind: "StatefulSet", Replicas: 0},
+	}, components)
+}
+
+func TestStewardVersionProvider(t *testing.T) {
+	f, err := stewardVersionProvider{}.Collect(t.Context(), FactCollector{StewardVersion: "v1.2.3"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", f["stewardVersion"])
+
+	f, err = stewardVersionProvider{}.Collect(t.Context(), FactCollector{})
+	require.NoError(t, err)
+	assert.Empty(t, f)
+}
@@ -0,0 +1,104 @@
+package facts
+
+import (
+	"context"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// capacityResources are the node resources summed up in the `capacity` dynamic fact
+var capacityResources = []corev1.ResourceName{
+	corev1.ResourceCPU,
+	corev1.ResourceMemory,
+	corev1.ResourceEphemeralStorage,
+	corev1.ResourcePods,
+}
+
+// ResourceTotals holds the summed up capacity and allocatable resources of a set of nodes
+type ResourceTotals struct {
+	Capacity    corev1.ResourceList `json:"capacity"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+}
+
+// CapacitySummary is reported as the `capacity` dynamic fact
+type CapacitySummary struct {
+	ResourceTotals
+	// ByRole holds the totals per node role, a node with multiple roles is counted for each role.
+	// It's only set if FactCollector.CapacityByRole is enabled.
+	ByRole map[string]ResourceTotals `json:"byRole,omitempty"`
+}
+
+type capacityProvider struct{}
+
+func (capacityProvider) Name() string { return "capacity" }
+
+func (capacityProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	nodes, err := col.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.DynamicClusterFacts{"capacity": summarizeCapacity(nodes, col.CapacityByRole)}, nil
+}
+
+func summarizeCapacity(nodes []corev1.Node, byRole bool) CapacitySummary {
+	summary := CapacitySummary{ResourceTotals: newResourceTotals()}
+	if byRole {
+		summary.ByRole = map[string]ResourceTotals{}
+	}
+	for _, node := range nodes {
+		summary.add(node)
+		if !byRole {
+			continue
+		}
+		for _, role := range nodeRoles(node) {
+			totals, ok := summary.ByRole[role]
+			if !ok {
+				totals = newResourceTotals()
+				summary.ByRole[role] = totals
+			}
+			totals.add(node)
+		}
+	}
+	return summary
+}
+
+func newResourceTotals() ResourceTotals {
+	t := ResourceTotals{
+		Capacity:    corev1.ResourceList{},
+		Allocatable: corev1.ResourceList{},
+	}
+	for _, r := range capacityResources {
+		t.Capacity[r] = *zeroQuantity(r)
+		t.Allocatable[r] = *zeroQuantity(r)
+	}
+	return t
+}
+
+// add sums up the resources of the node. It modifies the resource lists in place.
+func (t ResourceTotals) add(node corev1.Node) {
+	for _, r := range capacityResources {
+		addQuantity(t.Capacity, node.Status.Capacity, r)
+		addQuantity(t.Allocatable, node.Status.Allocatable, r)
+	}
+}
+
+func addQuantity(total, node corev1.ResourceList, r corev1.ResourceName) {
+	q, ok := node[r]
+	if !ok {
+		return
+	}
+	sum := total[r]
+	sum.Add(q)
+	total[r] = sum
+}
+
+func zeroQuantity(r corev1.ResourceName) *resource.Quantity {
+	switch r {
+	case corev1.ResourceCPU, corev1.ResourcePods:
+		return resource.NewQuantity(0, resource.DecimalSI)
+	default:
+		return resource.NewQuantity(0, resource.BinarySI)
+	}
+}
@@ -0,0 +1,89 @@
+package facts
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func makeCapacityNode(name, role, cpu, memory string) *corev1.Node {
+	node := makeNode(name, map[string]string{nodeRoleLabelPrefix + role: ""}, amd64Info)
+	node.Status.Capacity = corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse(cpu),
+		corev1.ResourceMemory:           resource.MustParse(memory),
+		corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+		corev1.ResourcePods:             resource.MustParse("110"),
+	}
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("3500m"),
+		corev1.ResourceMemory:           resource.MustParse(memory),
+		corev1.ResourceEphemeralStorage: resource.MustParse("90Gi"),
+		corev1.ResourcePods:             resource.MustParse("110"),
+	}
+	return &node
+}
+
+func TestCapacityProvider(t *testing.T) {
+	client := fake.NewClientset(
+		makeCapacityNode("master", "master", "4", "16Gi"),
+		makeCapacityNode("worker-1", "worker", "4", "32Gi"),
+		makeCapacityNode("worker-2", "worker", "4", "32Gi"),
+	)
+
+	tcs := map[string]struct {
+		byRole bool
+		out    string
+	}{
+		"total": {
+			out: `{
+				"capacity": {"cpu": "12", "memory": "80Gi", "ephemeral-storage": "300Gi", "pods": "330"},
+				"allocatable": {"cpu": "10500m", "memory": "80Gi", "ephemeral-storage": "270Gi", "pods": "330"}
+			}`,
+		},
+		"by role": {
+			byRole: true,
+			out: `{
+				"capacity": {"cpu": "12", "memory": "80Gi", "ephemeral-storage": "300Gi", "pods": "330"},
+				"allocatable": {"cpu": "10500m", "memory": "80Gi", "ephemeral-storage": "270Gi", "pods": "330"},
+				"byRole": {
+					"master": {
+						"capacity": {"cpu": "4", "memory": "16Gi", "ephemeral-storage": "100Gi", "pods": "110"},
+						"allocatable": {"cpu": "3500m", "memory": "16Gi", "ephemeral-storage": "90Gi", "pods": "110"}
+					},
+					"worker": {
+						"capacity": {"cpu": "8", "memory": "64Gi", "ephemeral-storage": "200Gi", "pods": "220"},
+						"allocatable": {"cpu": "7", "memory": "64Gi", "ephemeral-storage": "180Gi", "pods": "220"}
+					}
+				}
+			}`,
+		},
+	}
+
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			col := FactCollector{Client: client, CapacityByRole: tc.byRole}
+			facts, err := capacityProvider{}.Collect(t.Context(), col)
+			require.NoError(t, err)
+			out, err := json.Marshal(facts["capacity"])
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.out, string(out))
+		})
+	}
+}
+
+func TestCapacityProviderNoNodes(t *testing.T) {
+	col := FactCollector{Client: fake.NewClientset()}
+	facts, err := capacityProvider{}.Collect(t.Context(), col)
+	require.NoError(t, err)
+	out, err := json.Marshal(facts["capacity"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"capacity": {"cpu": "0", "memory": "0", "ephemeral-storage": "0", "pods": "0"},
+		"allocatable": {"cpu": "0", "memory": "0", "ephemeral-storage": "0", "pods": "0"}
+	}`, string(out))
+}
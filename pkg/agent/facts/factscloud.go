@@ -0,0 +1,134 @@
+package facts
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	detectedCloudFact = "detectedCloud"
+
+	legacyRegionLabel = "failure-domain.beta.kubernetes.io/region"
+	legacyZoneLabel   = "failure-domain.beta.kubernetes.io/zone"
+)
+
+var (
+	// providerIDClouds maps the scheme of a node's provider ID to the cloud type reported by steward.
+	// Schemes not listed here are reported as is.
+	providerIDClouds = map[string]string{
+		"gce":          "gcp",
+		"hcloud":       "hetzner",
+		"digitalocean": "digitalocean",
+	}
+	// nonCloudProviderIDs are provider ID schemes set by Kubernetes distributions and not by a cloud
+	nonCloudProviderIDs = []string{"k3s", "kind"}
+)
+
+// CloudInfo is reported as the `detectedCloud` dynamic fact
+type CloudInfo struct {
+	// Provider is the cloud type detected from the node provider IDs
+	Provider string `json:"provider,omitempty"`
+	// Region is detected from the `topology.kubernetes.io/region` node label
+	Region string `json:"region,omitempty"`
+	// Zones are detected from the `topology.kubernetes.io/zone` node label
+	Zones []string `json:"zones,omitempty"`
+	// Conflicts lists the configured facts (`cloud`, `region`) which don't match the detected values
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// DetectedCloud returns the cloud information detected by the cloud fact provider, if present.
+func DetectedCloud(facts *api.DynamicClusterFacts) (CloudInfo, bool) {
+	if facts == nil {
+		return CloudInfo{}, false
+	}
+	info, ok := (*facts)[detectedCloudFact].(CloudInfo)
+	return info, ok
+}
+
+type cloudProvider struct{}
+
+func (cloudProvider) Name() string { return "cloud" }
+
+func (cloudProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	nodes, err := col.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := detectCloud(nodes)
+	if info.Provider == "" && info.Region == "" && len(info.Zones) == 0 {
+		return nil, nil
+	}
+
+	if col.CloudType != "" && info.Provider != "" && col.CloudType != info.Provider {
+		klog.FromContext(ctx).Error(nil, "Configured cloud doesn't match detected cloud", "configured", col.CloudType, "detected", info.Provider)
+		info.Conflicts = append(info.Conflicts, "cloud")
+	}
+	if col.CloudRegion != "" && info.Region != "" && col.CloudRegion != info.Region {
+		klog.FromContext(ctx).Error(nil, "Configured region doesn't match detected region", "configured", col.CloudRegion, "detected", info.Region)
+		info.Conflicts = append(info.Conflicts, "region")
+	}
+	return api.DynamicClusterFacts{detectedCloudFact: info}, nil
+}
+
+// detectCloud returns the most common cloud provider and region of the nodes and all zones.
+func detectCloud(nodes []corev1.Node) CloudInfo {
+	clouds := map[string]int{}
+	regions := map[string]int{}
+	zones := map[string]bool{}
+	for _, node := range nodes {
+		if cloud := cloudFromProviderID(node.Spec.ProviderID); cloud != "" {
+			clouds[cloud]++
+		}
+		if region := labelValue(node, corev1.LabelTopologyRegion, legacyRegionLabel); region != "" {
+			regions[region]++
+		}
+		if zone := labelValue(node, corev1.LabelTopologyZone, legacyZoneLabel); zone != "" {
+			zones[zone] = true
+		}
+	}
+	return CloudInfo{
+		Provider: mostCommon(clouds),
+		Region:   mostCommon(regions),
+		Zones:    slices.Sorted(maps.Keys(zones)),
+	}
+}
+
+// cloudFromProviderID maps a provider ID like `aws:///eu-central-1a/i-0123` to a cloud type
+func cloudFromProviderID(providerID string) string {
+	scheme, _, found := strings.Cut(providerID, "://")
+	if !found || scheme == "" || slices.Contains(nonCloudProviderIDs, scheme) {
+		return ""
+	}
+	if cloud, ok := providerIDClouds[scheme]; ok {
+		return cloud
+	}
+	return scheme
+}
+
+// labelValue returns the value of the first label present on the node
+func labelValue(node corev1.Node, labels ...string) string {
+	for _, l := range labels {
+		if v := node.Labels[l]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// mostCommon returns the key with the highest count, ties are broken by the lexically smallest key
+func mostCommon(counts map[string]int) string {
+	best := ""
+	for _, k := range slices.Sorted(maps.Keys(counts)) {
+		if best == "" || cmp.Compare(counts[k], counts[best]) > 0 {
+			best = k
+		}
+	}
+	return best
+}
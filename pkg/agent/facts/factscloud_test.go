@@ -0,0 +1,124 @@
+package facts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func makeCloudNode(name, providerID string, labels map[string]string) *corev1.Node {
+	node := makeNode(name, labels, amd64Info)
+	node.Spec.ProviderID = providerID
+	return &node
+}
+
+func TestCloudProvider(t *testing.T) {
+	awsNodes := []*corev1.Node{
+		makeCloudNode("a", "aws:///eu-central-1a/i-0123", map[string]string{
+			corev1.LabelTopologyRegion: "eu-central-1",
+			corev1.LabelTopologyZone:   "eu-central-1a",
+		}),
+		makeCloudNode("b", "aws:///eu-central-1b/i-0456", map[string]string{
+			corev1.LabelTopologyRegion: "eu-central-1",
+			corev1.LabelTopologyZone:   "eu-central-1b",
+		}),
+		makeCloudNode("c", "aws:///eu-central-1a/i-0789", map[string]string{
+			legacyRegionLabel: "eu-central-1",
+			legacyZoneLabel:   "eu-central-1a",
+		}),
+	}
+
+	tcs := map[string]struct {
+		nodes  []*corev1.Node
+		cloud  string
+		region string
+		out    *CloudInfo
+	}{
+		"aws": {
+			nodes: awsNodes,
+			out: &CloudInfo{
+				Provider: "aws",
+				Region:   "eu-central-1",
+				Zones:    []string{"eu-central-1a", "eu-central-1b"},
+			},
+		},
+		"matching flags": {
+			nodes:  awsNodes,
+			cloud:  "aws",
+			region: "eu-central-1",
+			out: &CloudInfo{
+				Provider: "aws",
+				Region:   "eu-central-1",
+				Zones:    []string{"eu-central-1a", "eu-central-1b"},
+			},
+		},
+		"conflicting flags": {
+			nodes:  awsNodes,
+			cloud:  "cloudscale",
+			region: "rma",
+			out: &CloudInfo{
+				Provider:  "aws",
+				Region:    "eu-central-1",
+				Zones:     []string{"eu-central-1a", "eu-central-1b"},
+				Conflicts: []string{"cloud", "region"},
+			},
+		},
+		"gce": {
+			nodes: []*corev1.Node{
+				makeCloudNode("a", "gce://project/europe-west6-a/node-a", map[string]string{
+					corev1.LabelTopologyRegion: "europe-west6",
+				}),
+			},
+			out: &CloudInfo{
+				Provider: "gcp",
+				Region:   "europe-west6",
+			},
+		},
+		"k3s": {
+			nodes: []*corev1.Node{
+				makeCloudNode("a", "k3s://a", nil),
+			},
+			cloud: "cloudscale",
+			out:   nil,
+		},
+		"no provider ID": {
+			nodes: []*corev1.Node{
+				makeCloudNode("a", "", map[string]string{corev1.LabelTopologyZone: "zone-a"}),
+			},
+			out: &CloudInfo{
+				Zones: []string{"zone-a"},
+			},
+		},
+	}
+
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			client := fake.NewClientset()
+			for _, n := range tc.nodes {
+				_, err := client.CoreV1().Nodes().Create(t.Context(), n, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+			col := FactCollector{Client: client, CloudType: tc.cloud, CloudRegion: tc.region}
+			facts, err := cloudProvider{}.Collect(t.Context(), col)
+			require.NoError(t, err)
+
+			info, ok := DetectedCloud(&facts)
+			if tc.out == nil {
+				assert.False(t, ok)
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, *tc.out, info)
+		})
+	}
+}
+
+func TestMostCommon(t *testing.T) {
+	assert.Equal(t, "", mostCommon(map[string]int{}))
+	assert.Equal(t, "b", mostCommon(map[string]int{"a": 1, "b": 2}))
+	assert.Equal(t, "a", mostCommon(map[string]int{"a": 2, "b": 2}))
+}
@@ -0,0 +1,151 @@
+package facts
+
+import (
+	"context"
+	"strings"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// distributionNamespaces are well-known namespaces which are only present on some distributions
+var distributionNamespaces = []string{
+	"openshift-config",
+	"local-path-storage",
+}
+
+// clusterSignals holds the information distributions are detected from
+type clusterSignals struct {
+	// gitVersion is the API server version, for example `v1.30.4+k3s1`
+	gitVersion string
+	// openshift is true if the OpenShift ClusterVersion API is present
+	openshift  bool
+	nodes      []corev1.Node
+	namespaces map[string]bool
+}
+
+func (s clusterSignals) anyNode(match func(corev1.Node) bool) bool {
+	for _, n := range s.nodes {
+		if match(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s clusterSignals) anyNodeLabel(label string) bool {
+	return s.anyNode(func(n corev1.Node) bool {
+		_, ok := n.Labels[label]
+		return ok
+	})
+}
+
+func (s clusterSignals) anyProviderID(scheme string) bool {
+	return s.anyNode(func(n corev1.Node) bool {
+		return strings.HasPrefix(n.Spec.ProviderID, scheme+"://")
+	})
+}
+
+// distributionRules are evaluated in order, the first matching rule determines the distribution.
+// Distributions based on another distribution must come first, e.g. a kind node also looks like a kubeadm node.
+var distributionRules = []struct {
+	distribution string
+	match        func(clusterSignals) bool
+}{
+	{"openshift4", func(s clusterSignals) bool {
+		return s.openshift || s.namespaces["openshift-config"] || s.anyNodeLabel("node.openshift.io/os_id")
+	}},
+	{"rke2", func(s clusterSignals) bool {
+		return strings.Contains(s.gitVersion, "+rke2")
+	}},
+	{"k3s", func(s clusterSignals) bool {
+		return strings.Contains(s.gitVersion, "+k3s") || s.anyProviderID("k3s")
+	}},
+	{"k0s", func(s clusterSignals) bool {
+		return strings.Contains(s.gitVersion, "+k0s")
+	}},
+	{"eks", func(s clusterSignals) bool {
+		return strings.Contains(s.gitVersion, "-eks-") || s.anyNodeLabel("eks.amazonaws.com/nodegroup")
+	}},
+	{"gke", func(s clusterSignals) bool {
+		return strings.Contains(s.gitVersion, "-gke.") || s.anyNodeLabel("cloud.google.com/gke-nodepool")
+	}},
+	{"aks", func(s clusterSignals) bool {
+		return s.anyNodeLabel("kubernetes.azure.com/cluster")
+	}},
+	{"microk8s", func(s clusterSignals) bool {
+		return s.anyNodeLabel("microk8s.io/cluster")
+	}},
+	{"kind", func(s clusterSignals) bool {
+		return s.anyProviderID("kind") || (s.namespaces["local-path-storage"] && s.anyNode(func(n corev1.Node) bool {
+			return strings.HasSuffix(n.Name, "-control-plane") && strings.HasPrefix(n.Status.NodeInfo.OSImage, "Debian")
+		}))
+	}},
+	{"talos", func(s clusterSignals) bool {
+		return s.anyNode(func(n corev1.Node) bool {
+			return strings.HasPrefix(n.Status.NodeInfo.OSImage, "Talos")
+		})
+	}},
+}
+
+func detectDistribution(s clusterSignals) string {
+	for _, r := range distributionRules {
+		if r.match(s) {
+			return r.distribution
+		}
+	}
+	return ""
+}
+
+type distributionProvider struct{}
+
+func (distributionProvider) Name() string { return "distribution" }
+
+func (distributionProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	signals, err := col.fetchClusterSignals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	detected := detectDistribution(signals)
+	if detected == "" {
+		return nil, nil
+	}
+	if col.Distribution != "" && col.Distribution != detected {
+		klog.FromContext(ctx).Error(nil, "Configured distribution doesn't match detected distribution", "configured", col.Distribution, "detected", detected)
+	}
+	return api.DynamicClusterFacts{"detectedDistribution": detected}, nil
+}
+
+func (col FactCollector) fetchClusterSignals(ctx context.Context) (clusterSignals, error) {
+	signals := clusterSignals{namespaces: map[string]bool{}}
+
+	kubeVersion, err := col.fetchKubernetesVersion(ctx)
+	if err != nil {
+		return signals, err
+	}
+	signals.gitVersion = kubeVersion.GitVersion
+
+	ocpVersion, err := col.fetchOpenshiftVersion(ctx)
+	if err != nil {
+		return signals, err
+	}
+	signals.openshift = ocpVersion != nil
+
+	signals.nodes, err = col.listNodes(ctx)
+	if err != nil {
+		return signals, err
+	}
+
+	for _, ns := range distributionNamespaces {
+		_, err := col.Client.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+		if err == nil {
+			signals.namespaces[ns] = true
+		} else if !errors.IsNotFound(err) {
+			return signals, err
+		}
+	}
+	return signals, nil
+}
@@ -0,0 +1,103 @@
+package facts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestDetectDistribution(t *testing.T) {
+	labeled := func(label string) []corev1.Node {
+		return []corev1.Node{makeNode("node", map[string]string{label: "x"}, amd64Info)}
+	}
+	withProviderID := func(name, providerID string, info corev1.NodeSystemInfo) []corev1.Node {
+		n := makeNode(name, nil, info)
+		n.Spec.ProviderID = providerID
+		return []corev1.Node{n}
+	}
+	talos := amd64Info
+	talos.OSImage = "Talos (v1.8.0)"
+	debian := amd64Info
+	debian.OSImage = "Debian GNU/Linux 12 (bookworm)"
+
+	tcs := map[string]struct {
+		in  clusterSignals
+		out string
+	}{
+		"openshift api": {
+			in:  clusterSignals{gitVersion: "v1.30.4", openshift: true},
+			out: "openshift4",
+		},
+		"openshift namespace": {
+			in:  clusterSignals{gitVersion: "v1.30.4", namespaces: map[string]bool{"openshift-config": true}},
+			out: "openshift4",
+		},
+		"openshift node": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: labeled("node.openshift.io/os_id")},
+			out: "openshift4",
+		},
+		"rke2": {
+			in:  clusterSignals{gitVersion: "v1.30.4+rke2r1"},
+			out: "rke2",
+		},
+		"k3s version": {
+			in:  clusterSignals{gitVersion: "v1.30.4+k3s1"},
+			out: "k3s",
+		},
+		"k3s provider id": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: withProviderID("node", "k3s://node", amd64Info)},
+			out: "k3s",
+		},
+		"k0s": {
+			in:  clusterSignals{gitVersion: "v1.30.4+k0s"},
+			out: "k0s",
+		},
+		"eks version": {
+			in:  clusterSignals{gitVersion: "v1.30.4-eks-a737599"},
+			out: "eks",
+		},
+		"eks node": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: labeled("eks.amazonaws.com/nodegroup")},
+			out: "eks",
+		},
+		"gke": {
+			in:  clusterSignals{gitVersion: "v1.30.4-gke.1348000"},
+			out: "gke",
+		},
+		"aks": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: labeled("kubernetes.azure.com/cluster")},
+			out: "aks",
+		},
+		"microk8s": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: labeled("microk8s.io/cluster")},
+			out: "microk8s",
+		},
+		"kind provider id": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: withProviderID("node", "kind://docker/kind/node", amd64Info)},
+			out: "kind",
+		},
+		"kind namespace": {
+			in: clusterSignals{
+				gitVersion: "v1.30.4",
+				nodes:      withProviderID("kind-control-plane", "", debian),
+				namespaces: map[string]bool{"local-path-storage": true},
+			},
+			out: "kind",
+		},
+		"talos": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: withProviderID("node", "", talos)},
+			out: "talos",
+		},
+		"unknown": {
+			in:  clusterSignals{gitVersion: "v1.30.4", nodes: labeled("node-role.kubernetes.io/worker")},
+			out: "",
+		},
+	}
+
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.out, detectDistribution(tc.in))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package facts
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/klog/v2"
+)
+
+const (
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	legacyNodeRoleLabel = "kubernetes.io/role"
+	// noNodeRole is used for nodes without any role label
+	noNodeRole = "none"
+)
+
+// NodeSummary is reported as the `nodes` dynamic fact
+type NodeSummary struct {
+	Count int `json:"count"`
+	// Roles counts the nodes per role, a node with multiple roles is counted once for each role
+	Roles           map[string]int `json:"roles"`
+	Architectures   map[string]int `json:"architectures"`
+	KubeletVersions map[string]int `json:"kubeletVersions"`
+	// KubeletVersionSkew is the number of minor versions the oldest kubelet is behind the API server
+	KubeletVersionSkew *int `json:"kubeletVersionSkew,omitempty"`
+	// Platforms groups the nodes by architecture and software versions
+	Platforms []NodePlatform `json:"platforms"`
+}
+
+// NodePlatform is a group of nodes sharing the same architecture and software versions
+type NodePlatform struct {
+	Architecture            string `json:"architecture"`
+	OperatingSystem         string `json:"operatingSystem"`
+	OSImage                 string `json:"osImage"`
+	KernelVersion           string `json:"kernelVersion"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+	KubeletVersion          string `json:"kubeletVersion"`
+	Count                   int    `json:"count"`
+}
+
+type nodesProvider struct{}
+
+func (nodesProvider) Name() string { return "nodes" }
+
+func (nodesProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	nodes, err := col.listNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kubeVersion, err := col.fetchKubernetesVersion(ctx)
+	if err != nil {
+		// The summary is still useful without the version skew
+		klog.FromContext(ctx).Error(err, "Unable to determine kubelet version skew")
+	}
+	return api.DynamicClusterFacts{"nodes": summarizeNodes(ctx, nodes, kubeVersion)}, nil
+}
+
+// listNodes returns the nodes, they're only listed once per collection
+func (col FactCollector) listNodes(ctx context.Context) ([]corev1.Node, error) {
+	return col.cache().nodes.get(func() ([]corev1.Node, error) {
+		nodes, err := col.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list nodes: %w", err)
+		}
+		return nodes.Items, nil
+	})
+}
+
+func summarizeNodes(ctx context.Context, nodes []corev1.Node, apiVersion *version.Info) NodeSummary {
+	summary := NodeSummary{
+		Count:           len(nodes),
+		Roles:           map[string]int{},
+		Architectures:   map[string]int{},
+		KubeletVersions: map[string]int{},
+		Platforms:       []NodePlatform{},
+	}
+
+	var oldestKubelet *utilversion.Version
+	for _, node := range nodes {
+		info := node.Status.NodeInfo
+		for _, role := range nodeRoles(node) {
+			summary.Roles[role]++
+		}
+		summary.Architectures[info.Architecture]++
+		summary.KubeletVersions[info.KubeletVersion]++
+
+		platform := NodePlatform{
+			Architecture:            info.Architecture,
+			OperatingSystem:         info.OperatingSystem,
+			OSImage:                 info.OSImage,
+			KernelVersion:           info.KernelVersion,
+			ContainerRuntimeVersion: info.ContainerRuntimeVersion,
+			KubeletVersion:          info.KubeletVersion,
+		}
+		i := slices.IndexFunc(summary.Platforms, func(p NodePlatform) bool {
+			p.Count = 0
+			return p == platform
+		})
+		if i < 0 {
+			summary.Platforms = append(summary.Platforms, platform)
+			i = len(summary.Platforms) - 1
+		}
+		summary.Platforms[i].Count++
+
+		kubelet, err := utilversion.ParseGeneric(info.KubeletVersion)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Unable to parse kubelet version", "kind", "Node", "name", node.Name, "version", info.KubeletVersion)
+			continue
+		}
+		if oldestKubelet == nil || kubelet.LessThan(oldestKubelet) {
+			oldestKubelet = kubelet
+		}
+	}
+
+	slices.SortFunc(summary.Platforms, func(a, b NodePlatform) int {
+		return cmp.Or(
+			cmp.Compare(a.Architecture, b.Architecture),
+			cmp.Compare(a.OperatingSystem, b.OperatingSystem),
+			cmp.Compare(a.OSImage, b.OSImage),
+			cmp.Compare(a.KernelVersion, b.KernelVersion),
+			cmp.Compare(a.ContainerRuntimeVersion, b.ContainerRuntimeVersion),
+			cmp.Compare(a.KubeletVersion, b.KubeletVersion),
+		)
+	})
+
+	if apiVersion != nil && oldestKubelet != nil {
+		apiMinor, err := utilversion.ParseGeneric(apiVersion.Major + "." + apiVersion.Minor)
+		if err == nil && apiMinor.Major() == oldestKubelet.Major() {
+			skew := int(apiMinor.Minor()) - int(oldestKubelet.Minor())
+			summary.KubeletVersionSkew = &skew
+		}
+	}
+
+	return summary
+}
+
+// nodeRoles returns the sorted roles of the node based on the `node-role.kubernetes.io/<role>` and `kubernetes.io/role` labels.
+func nodeRoles(node corev1.Node) []string {
+	roles := []string{}
+	for k, v := range node.Labels {
+		if role, ok := strings.CutPrefix(k, nodeRoleLabelPrefix); ok && role != "" {
+			roles = append(roles, role)
+		} else if k == legacyNodeRoleLabel && v != "" {
+			roles = append(roles, v)
+		}
+	}
+	if len(roles) == 0 {
+		return []string{noNodeRole}
+	}
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
@@ -0,0 +1,88 @@
+package facts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+func makeNode(name string, labels map[string]string, info corev1.NodeSystemInfo) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status:     corev1.NodeStatus{NodeInfo: info},
+	}
+}
+
+var (
+	amd64Info = corev1.NodeSystemInfo{
+		Architecture:            "amd64",
+		OperatingSystem:         "linux",
+		OSImage:                 "Ubuntu 24.04 LTS",
+		KernelVersion:           "6.8.0-45-generic",
+		ContainerRuntimeVersion: "containerd://1.7.22",
+		KubeletVersion:          "v1.31.1",
+	}
+	arm64Info = corev1.NodeSystemInfo{
+		Architecture:            "arm64",
+		OperatingSystem:         "linux",
+		OSImage:                 "Ubuntu 22.04 LTS",
+		KernelVersion:           "5.15.0-119-generic",
+		ContainerRuntimeVersion: "containerd://1.7.12",
+		KubeletVersion:          "v1.29.8+k3s1",
+	}
+)
+
+func TestSummarizeNodes(t *testing.T) {
+	nodes := []corev1.Node{
+		makeNode("master", map[string]string{
+			"node-role.kubernetes.io/master":        "",
+			"node-role.kubernetes.io/control-plane": "",
+		}, amd64Info),
+		makeNode("worker-1", map[string]string{"node-role.kubernetes.io/worker": ""}, amd64Info),
+		makeNode("worker-2", map[string]string{"kubernetes.io/role": "worker"}, arm64Info),
+		makeNode("other", nil, arm64Info),
+	}
+
+	summary := summarizeNodes(t.Context(), nodes, &version.Info{Major: "1", Minor: "31"})
+	assert.Equal(t, 4, summary.Count)
+	assert.Equal(t, map[string]int{"master": 1, "control-plane": 1, "worker": 2, "none": 1}, summary.Roles)
+	assert.Equal(t, map[string]int{"amd64": 2, "arm64": 2}, summary.Architectures)
+	assert.Equal(t, map[string]int{"v1.31.1": 2, "v1.29.8+k3s1": 2}, summary.KubeletVersions)
+	if assert.NotNil(t, summary.KubeletVersionSkew) {
+		assert.Equal(t, 2, *summary.KubeletVersionSkew)
+	}
+	assert.Equal(t, []NodePlatform{
+		{
+			Architecture:            "amd64",
+			OperatingSystem:         "linux",
+			OSImage:                 "Ubuntu 24.04 LTS",
+			KernelVersion:           "6.8.0-45-generic",
+			ContainerRuntimeVersion: "containerd://1.7.22",
+			KubeletVersion:          "v1.31.1",
+			Count:                   2,
+		},
+		{
+			Architecture:            "arm64",
+			OperatingSystem:         "linux",
+			OSImage:                 "Ubuntu 22.04 LTS",
+			KernelVersion:           "5.15.0-119-generic",
+			ContainerRuntimeVersion: "containerd://1.7.12",
+			KubeletVersion:          "v1.29.8+k3s1",
+			Count:                   2,
+		},
+	}, summary.Platforms)
+}
+
+func TestSummarizeNodesWithoutVersion(t *testing.T) {
+	summary := summarizeNodes(t.Context(), []corev1.Node{makeNode("node", nil, amd64Info)}, nil)
+	assert.Equal(t, 1, summary.Count)
+	assert.Nil(t, summary.KubeletVersionSkew)
+
+	summary = summarizeNodes(t.Context(), nil, &version.Info{Major: "1", Minor: "31"})
+	assert.Equal(t, 0, summary.Count)
+	assert.Nil(t, summary.KubeletVersionSkew)
+	assert.Empty(t, summary.Platforms)
+}
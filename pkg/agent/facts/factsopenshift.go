@@ -9,7 +9,7 @@ import (
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 )
 
 type OpenshiftVersionDesired struct {
@@ -37,26 +37,30 @@ type OpenshiftRoute struct {
 	}
 }
 
+// fetchOpenshiftVersion returns the OpenShift version, or nil if the cluster isn't running OpenShift.
+// It's only fetched once per collection.
 func (col FactCollector) fetchOpenshiftVersion(ctx context.Context) (*SemanticVersion, error) {
-	body, err := col.Client.RESTClient().Get().AbsPath("/apis/config.openshift.io/v1/clusterversions/version").Do(ctx).Raw()
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// API server doesn't know `clusterversions` or there is no resource, so we are not running on openshift.
-			return nil, nil
+	return col.cache().openshiftVersion.get(func() (*SemanticVersion, error) {
+		body, err := col.Client.Discovery().RESTClient().Get().AbsPath("/apis/config.openshift.io/v1/clusterversions/version").Do(ctx).Raw()
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// API server doesn't know `clusterversions` or there is no resource, so we are not running on openshift.
+				return nil, nil
+			}
+			return nil, err
+		}
+		var version OpenshiftVersion
+		err = json.Unmarshal(body, &version)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the openshift version: %w", err)
 		}
-		return nil, err
-	}
-	var version OpenshiftVersion
-	err = json.Unmarshal(body, &version)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse the openshift version: %w", err)
-	}
 
-	return processOpenshiftVersion(version)
+		return processOpenshiftVersion(ctx, version)
+	})
 }
 
 func (col FactCollector) fetchOpenshiftOAuthRoute(ctx context.Context) (string, error) {
-	body, err := col.Client.RESTClient().Get().
+	body, err := col.Client.Discovery().RESTClient().Get().
 		AbsPath(
 			path.Join("/apis/route.openshift.io/v1/namespaces", col.OAuthRouteNamespace, "routes", col.OAuthRouteName),
 		).Do(ctx).Raw()
@@ -82,7 +86,7 @@ type SemanticVersion struct {
 	Patch string
 }
 
-func processOpenshiftVersion(v OpenshiftVersion) (*SemanticVersion, error) {
+func processOpenshiftVersion(ctx context.Context, v OpenshiftVersion) (*SemanticVersion, error) {
 	currentVersion := ""
 	lastedUpdate := time.Time{}
 	for _, h := range v.Status.History {
@@ -93,7 +97,7 @@ func processOpenshiftVersion(v OpenshiftVersion) (*SemanticVersion, error) {
 	}
 	versionFact, err := parseSematicVersion(currentVersion)
 	if err != nil {
-		klog.Warningf("unable to parse version %v : %s\nFalling back to desired version", versionFact, err)
+		klog.FromContext(ctx).Error(err, "Unable to parse OpenShift version, falling back to desired version", "version", versionFact)
 		versionFact, err = parseSematicVersion(v.Status.Desired.Version)
 		if err != nil {
 			return nil, fmt.Errorf("unable to parse desiredVersion: %w", err)
@@ -193,7 +193,7 @@ func TestProcessOpenshiftVersion(t *testing.T) {
 	}
 	for k, tc := range tcs {
 		t.Run(k, func(t *testing.T) {
-			v, err := processOpenshiftVersion(tc.in)
+			v, err := processOpenshiftVersion(t.Context(), tc.in)
 			if tc.fail {
 				assert.Error(t, err)
 				return
@@ -0,0 +1,109 @@
+package facts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+)
+
+// DefaultProviderTimeout is used if no timeout is configured on the FactCollector.
+const DefaultProviderTimeout = 10 * time.Second
+
+// FactProvider collects a set of dynamic facts.
+type FactProvider interface {
+	// Name returns the unique name of the provider, which is used to enable or disable it.
+	Name() string
+	// Collect returns the facts gathered by the provider.
+	// The collector gives access to the Kubernetes client and the collector configuration.
+	Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error)
+}
+
+// providers holds all registered fact providers in the order they're run.
+// Facts returned by a provider override facts with the same key returned by earlier providers.
+// The additional facts come first so they can't override any of the built-in facts.
+var providers = []FactProvider{
+	additionalFactsProvider{},
+	kubernetesVersionProvider{},
+	openshiftVersionProvider{},
+	openshiftOAuthRouteProvider{},
+	nodesProvider{},
+	capacityProvider{},
+	apisProvider{},
+	cloudProvider{},
+	distributionProvider{},
+	argoCDProvider{},
+	stewardVersionProvider{},
+}
+
+// RegisterProvider adds a fact provider to the registry.
+// It panics if a provider with the same name is already registered.
+func RegisterProvider(p FactProvider) {
+	if slices.Contains(Providers(), p.Name()) {
+		panic(fmt.Sprintf("fact provider %q is already registered", p.Name()))
+	}
+	providers = append(providers, p)
+}
+
+// Providers returns the names of all registered fact providers.
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for _, p := range providers {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+type additionalFactsProvider struct{}
+
+func (additionalFactsProvider) Name() string { return "additional-facts" }
+
+func (additionalFactsProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	additionalFacts, err := col.fetchAdditionalFacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	facts := api.DynamicClusterFacts{}
+	for k, v := range additionalFacts {
+		facts[k] = v
+	}
+	return facts, nil
+}
+
+type kubernetesVersionProvider struct{}
+
+func (kubernetesVersionProvider) Name() string { return "kubernetes-version" }
+
+func (kubernetesVersionProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	kubeVersion, err := col.fetchKubernetesVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.DynamicClusterFacts{"kubernetesVersion": kubeVersion}, nil
+}
+
+type openshiftVersionProvider struct{}
+
+func (openshiftVersionProvider) Name() string { return "openshift-version" }
+
+func (openshiftVersionProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	ocpVersion, err := col.fetchOpenshiftVersion(ctx)
+	if err != nil || ocpVersion == nil {
+		return nil, err
+	}
+	return api.DynamicClusterFacts{"openshiftVersion": ocpVersion}, nil
+}
+
+type openshiftOAuthRouteProvider struct{}
+
+func (openshiftOAuthRouteProvider) Name() string { return "openshift-oauth-route" }
+
+func (openshiftOAuthRouteProvider) Collect(ctx context.Context, col FactCollector) (api.DynamicClusterFacts, error) {
+	ocpOAuthRoute, err := col.fetchOpenshiftOAuthRoute(ctx)
+	if err != nil || ocpOAuthRoute == "" {
+		return nil, err
+	}
+	return api.DynamicClusterFacts{"openshiftOAuthRoute": ocpOAuthRoute}, nil
+}
@@ -0,0 +1,100 @@
+package facts
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubProvider struct {
+	name    string
+	collect func(ctx context.Context) (api.DynamicClusterFacts, error)
+}
+
+func (p stubProvider) Name() string { return p.name }
+
+func (p stubProvider) Collect(ctx context.Context, _ FactCollector) (api.DynamicClusterFacts, error) {
+	return p.collect(ctx)
+}
+
+func staticProvider(name string, facts api.DynamicClusterFacts) stubProvider {
+	return stubProvider{name: name, collect: func(context.Context) (api.DynamicClusterFacts, error) {
+		return facts, nil
+	}}
+}
+
+func withProviders(t *testing.T, ps ...FactProvider) {
+	orig := providers
+	providers = ps
+	t.Cleanup(func() { providers = orig })
+}
+
+func TestFetchDynamicFacts(t *testing.T) {
+	withProviders(t,
+		staticProvider("first", api.DynamicClusterFacts{"a": "first", "b": "first"}),
+		staticProvider("second", api.DynamicClusterFacts{"b": "second"}),
+		stubProvider{name: "failing", collect: func(context.Context) (api.DynamicClusterFacts, error) {
+			return nil, errors.New("boom")
+		}},
+		stubProvider{name: "panicking", collect: func(context.Context) (api.DynamicClusterFacts, error) {
+			panic("boom")
+		}},
+		stubProvider{name: "slow", collect: func(ctx context.Context) (api.DynamicClusterFacts, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+		staticProvider("last", api.DynamicClusterFacts{"c": "last"}),
+	)
+
+	tcs := map[string]struct {
+		col FactCollector
+		out api.DynamicClusterFacts
+	}{
+		"all": {
+			col: FactCollector{ProviderTimeout: time.Millisecond},
+			out: api.DynamicClusterFacts{"a": "first", "b": "second", "c": "last"},
+		},
+		"disabled": {
+			col: FactCollector{ProviderTimeout: time.Millisecond, DisabledProviders: []string{"second", "last"}},
+			out: api.DynamicClusterFacts{"a": "first", "b": "first"},
+		},
+		"enabled": {
+			col: FactCollector{ProviderTimeout: time.Millisecond, EnabledProviders: []string{"second", "last"}},
+			out: api.DynamicClusterFacts{"b": "second", "c": "last"},
+		},
+		"enabled and disabled": {
+			col: FactCollector{ProviderTimeout: time.Millisecond, EnabledProviders: []string{"second", "last"}, DisabledProviders: []string{"last"}},
+			out: api.DynamicClusterFacts{"b": "second"},
+		},
+	}
+
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			facts, err := tc.col.FetchDynamicFacts(t.Context())
+			require.NoError(t, err)
+			assert.Equal(t, tc.out, *facts)
+		})
+	}
+}
+
+func TestFactCollectorValidate(t *testing.T) {
+	withProviders(t, staticProvider("first", nil), staticProvider("second", nil))
+
+	assert.NoError(t, FactCollector{}.Validate())
+	assert.NoError(t, FactCollector{EnabledProviders: []string{"first"}, DisabledProviders: []string{"second"}}.Validate())
+	assert.Error(t, FactCollector{EnabledProviders: []string{"third"}}.Validate())
+	assert.Error(t, FactCollector{DisabledProviders: []string{"third"}}.Validate())
+}
+
+func TestRegisterProvider(t *testing.T) {
+	withProviders(t, staticProvider("first", nil))
+
+	RegisterProvider(staticProvider("second", nil))
+	assert.Equal(t, []string{"first", "second"}, Providers())
+	assert.Panics(t, func() { RegisterProvider(staticProvider("first", nil)) })
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLivenessThreshold  = 10 * time.Minute
+	defaultReadinessThreshold = 15 * time.Minute
+	// loopHeartbeatInterval is how often an idle sync loop reports that it's alive
+	loopHeartbeatInterval = 10 * time.Second
+)
+
+// health tracks the progress of the agent for the liveness and readiness probes.
+// The methods recording progress are safe to call on a nil health.
+type health struct {
+	mu sync.Mutex
+
+	livenessThreshold  time.Duration
+	readinessThreshold time.Duration
+	// maxSyncDuration is the timeout of a sync, the loop doesn't report while it's syncing
+	maxSyncDuration time.Duration
+
+	// loopHeartbeat is updated by the sync loop whenever it isn't busy with a sync
+	loopHeartbeat time.Time
+	// lastSync is the time of the last successful sync with Lieutenant
+	lastSync time.Time
+	// lastArgoCDReconcile is the time Argo CD was last reconciled successfully
+	lastArgoCDReconcile time.Time
+	// standby is set while the agent waits to become the leader, standby replicas are always live and ready
+	standby bool
+
+	now func() time.Time
+}
+
+func newHealth(livenessThreshold, readinessThreshold time.Duration) *health {
+	if livenessThreshold <= 0 {
+		livenessThreshold = defaultLivenessThreshold
+	}
+	if readinessThreshold <= 0 {
+		readinessThreshold = defaultReadinessThreshold
+	}
+	h := &health{
+		livenessThreshold:  livenessThreshold,
+		readinessThreshold: readinessThreshold,
+		now:                time.Now,
+	}
+	h.loopHeartbeat = h.now()
+	return h
+}
+
+func (h *health) beat() {
+	h.set(func(h *health) { h.loopHeartbeat = h.now() })
+}
+
+func (h *health) synced() {
+	h.set(func(h *health) { h.lastSync = h.now() })
+}
+
+func (h *health) argoCDReconciled() {
+	h.set(func(h *health) { h.lastArgoCDReconcile = h.now() })
+}
+
+// setMaxSyncDuration raises the liveness threshold above the timeout of a sync, so a long sync doesn't fail the liveness probe
+func (h *health) setMaxSyncDuration(d time.Duration) {
+	h.set(func(h *health) { h.maxSyncDuration = d })
+}
+
+// waitForLeadership marks the agent as standby until it's leading
+func (h *health) waitForLeadership() {
+	h.set(func(h *health) { h.standby = true })
+}
+
+// leading ends the standby, the liveness threshold applies from now on
+func (h *health) leading() {
+	h.set(func(h *health) {
+		h.standby = false
+		h.loopHeartbeat = h.now()
+	})
+}
+
+func (h *health) set(f func(*health)) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f(h)
+}
+
+// live returns an error if the sync loop didn't report for longer than the liveness threshold,
+// or than the timeout of a sync if that's longer. Standby replicas are always live.
+func (h *health) live() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.standby {
+		return nil
+	}
+	threshold := max(h.livenessThreshold, h.maxSyncDuration+loopHeartbeatInterval)
+	if age := h.now().Sub(h.loopHeartbeat); age > threshold {
+		return fmt.Errorf("sync loop is stuck, last heartbeat %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// ready returns an error if the last successful sync with Lieutenant or Argo CD reconcile is older than the readiness threshold.
+// Standby replicas are always ready, so they don't block rollouts while the old replica is leading.
+func (h *health) ready() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.standby {
+		return nil
+	}
+	if err := h.recent("Lieutenant sync", h.lastSync); err != nil {
+		return err
+	}
+	return h.recent("Argo CD reconcile", h.lastArgoCDReconcile)
+}
+
+func (h *health) recent(what string, t time.Time) error {
+	if t.IsZero() {
+		return fmt.Errorf("no successful %s yet", what)
+	}
+	if age := h.now().Sub(t); age > h.readinessThreshold {
+		return fmt.Errorf("last successful %s %s ago", what, age.Round(time.Second))
+	}
+	return nil
+}
+
+// handler returns the liveness (`/healthz`) and readiness (`/readyz`) probe handlers
+func (h *health) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", probeHandler(h.live))
+	mux.HandleFunc("/readyz", probeHandler(h.ready))
+	return mux
+}
+
+func probeHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
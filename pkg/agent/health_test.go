@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHealth(time.Minute, 10*time.Minute)
+	h.now = func() time.Time { return now }
+	h.beat()
+
+	assert.NoError(t, h.live())
+	assert.ErrorContains(t, h.ready(), "no successful Lieutenant sync yet")
+
+	h.synced()
+	assert.ErrorContains(t, h.ready(), "no successful Argo CD reconcile yet")
+
+	h.argoCDReconciled()
+	assert.NoError(t, h.ready())
+
+	now = now.Add(2 * time.Minute)
+	assert.ErrorContains(t, h.live(), "sync loop is stuck")
+	assert.NoError(t, h.ready())
+	h.beat()
+	assert.NoError(t, h.live())
+
+	now = now.Add(9 * time.Minute)
+	h.synced()
+	h.beat()
+	assert.ErrorContains(t, h.ready(), "last successful Argo CD reconcile 11m0s ago")
+}
+
+func TestHealthLongSync(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHealth(10*time.Minute, 15*time.Minute)
+	h.now = func() time.Time { return now }
+	h.beat()
+	a := &Agent{ArgoCDUpgrade: true, ArgoCDUpgradeTimeout: 30 * time.Minute, health: h}
+	h.setMaxSyncDuration(a.syncTimeout())
+
+	// A sync may take the upgrade timeout and a minute to register the cluster
+	now = now.Add(31 * time.Minute)
+	assert.NoError(t, h.live())
+	now = now.Add(time.Minute)
+	assert.ErrorContains(t, h.live(), "sync loop is stuck")
+}
+
+func TestHealthNil(t *testing.T) {
+	var h *health
+	assert.NotPanics(t, func() {
+		h.beat()
+		h.synced()
+		h.argoCDReconciled()
+	})
+}
+
+func TestHealthHandler(t *testing.T) {
+	h := newHealth(0, 0)
+	srv := httptest.NewServer(h.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+
+	h.synced()
+	h.argoCDReconciled()
+	resp, err = http.Get(srv.URL + "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestHealthStandby(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHealth(time.Minute, 10*time.Minute)
+	h.now = func() time.Time { return now }
+	h.waitForLeadership()
+
+	now = now.Add(time.Hour)
+	assert.NoError(t, h.live())
+	assert.NoError(t, h.ready())
+
+	h.leading()
+	assert.NoError(t, h.live())
+	assert.ErrorContains(t, h.ready(), "no successful Lieutenant sync yet")
+
+	now = now.Add(2 * time.Minute)
+	assert.ErrorContains(t, h.live(), "sync loop is stuck")
+}
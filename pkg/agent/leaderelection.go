@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+const (
+	defaultLeaseName          = "steward-leader"
+	defaultLeaseDuration      = 15 * time.Second
+	defaultLeaseRenewDeadline = 10 * time.Second
+	defaultLeaseRetryPeriod   = 2 * time.Second
+)
+
+// errLeadershipLost is returned if the agent stopped leading before it was shut down.
+// The agent exits so a fresh instance can take part in the next election.
+var errLeadershipLost = errors.New("leadership lost")
+
+// runLeaderElection calls run once this instance acquired the lease and returns after run returned.
+// The context passed to run is done on shutdown and when losing the lease, the lease context only when losing the lease.
+// The lease is released when the context is done, so a standby replica takes over without waiting for it to expire.
+func (a *Agent) runLeaderElection(ctx context.Context, client kubernetes.Interface, run func(ctx, lease context.Context)) error {
+	namespace := a.LeaderElectionNamespace
+	if namespace == "" {
+		namespace = a.Namespace
+	}
+	name := a.LeaderElectionLeaseName
+	if name == "" {
+		name = defaultLeaseName
+	}
+	log := klog.FromContext(ctx).WithValues("namespace", namespace, "lease", name)
+	identity, err := leaderIdentity()
+	if err != nil {
+		return err
+	}
+
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, namespace, name, client.CoreV1(), client.CoordinationV1(), resourcelock.ResourceLockConfig{
+		Identity: identity,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create leader election lock: %w", err)
+	}
+
+	// The election runs on its own context, so the lease is only released after the sync loop returned.
+	// Otherwise a standby replica could take over while a sync is still running during the shutdown grace period.
+	electionCtx, stopElection := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopElection()
+	// Run starts the leader callback in a goroutine, which may only be scheduled after Run returned.
+	// The callback only runs the sync loop if runLeaderElection didn't return yet, and then runLeaderElection waits for it.
+	var (
+		mu       sync.Mutex
+		started  bool
+		returned bool
+	)
+	stopWaiting := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !started {
+			stopElection()
+		}
+	})
+	defer stopWaiting()
+
+	done := make(chan struct{})
+	a.health.waitForLeadership()
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            name,
+		LeaseDuration:   cmp.Or(a.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline:   cmp.Or(a.LeaseRenewDeadline, defaultLeaseRenewDeadline),
+		RetryPeriod:     cmp.Or(a.LeaseRetryPeriod, defaultLeaseRetryPeriod),
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				mu.Lock()
+				if returned {
+					mu.Unlock()
+					return
+				}
+				started = true
+				mu.Unlock()
+				defer close(done)
+				defer stopElection()
+				log.Info("Acquired lease", "identity", identity)
+				metrics.Leader.Set(1)
+				a.health.leading()
+
+				// Stop on shutdown and when losing the lease
+				runCtx, cancel := context.WithCancel(leaderCtx)
+				defer cancel()
+				defer context.AfterFunc(ctx, cancel)()
+				run(runCtx, leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				metrics.Leader.Set(0)
+				log.Info("Stopped leading")
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					log.Info("Waiting for lease", "leader", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid leader election configuration: %w", err)
+	}
+
+	log.Info("Waiting to acquire lease")
+	elector.Run(electionCtx)
+	mu.Lock()
+	returned = true
+	leading := started
+	mu.Unlock()
+	if leading {
+		// Run cancels the context of the leader callback when it returns, wait for the sync loop to finish
+		<-done
+	}
+	if ctx.Err() == nil {
+		return errLeadershipLost
+	}
+	return nil
+}
+
+// leaderIdentity returns a unique identity of this instance, prefixed with the host name which is the pod name in the cluster
+func leaderIdentity() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the leader election identity: %w", err)
+	}
+	return hostname + "_" + rand.String(8), nil
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaderElection(t *testing.T) {
+	client := fake.NewClientset()
+	newAgent := func() *Agent {
+		return &Agent{
+			Namespace:          "syn",
+			LeaseDuration:      time.Second,
+			LeaseRenewDeadline: 500 * time.Millisecond,
+			LeaseRetryPeriod:   100 * time.Millisecond,
+			health:             newHealth(0, 0),
+		}
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	leading := make(chan string, 2)
+	results := make(chan result, 2)
+	start := func(name string, a *Agent) context.CancelFunc {
+		ctx, cancel := context.WithCancel(t.Context())
+		go func() {
+			err := a.runLeaderElection(ctx, client, func(ctx, _ context.Context) {
+				leading <- name
+				<-ctx.Done()
+			})
+			results <- result{name, err}
+		}()
+		return cancel
+	}
+
+	first, second := newAgent(), newAgent()
+	cancelFirst := start("first", first)
+	require.Equal(t, "first", waitFor(t, leading))
+	lease, err := client.CoordinationV1().Leases("syn").Get(t.Context(), "steward-leader", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, *lease.Spec.HolderIdentity)
+
+	cancelSecond := start("second", second)
+	defer cancelSecond()
+	select {
+	case name := <-leading:
+		t.Fatalf("%s started leading while the lease is held", name)
+	case <-time.After(300 * time.Millisecond):
+	}
+	assert.NoError(t, second.health.ready(), "standby replicas are ready")
+
+	cancelFirst()
+	assert.Equal(t, result{"first", nil}, waitFor(t, results))
+	assert.Equal(t, "second", waitFor(t, leading))
+	assert.ErrorContains(t, second.health.ready(), "no successful Lieutenant sync yet")
+
+	cancelSecond()
+	assert.Equal(t, result{"second", nil}, waitFor(t, results))
+}
+
+func TestLeaderElectionShutdownWhileAcquiring(t *testing.T) {
+	for range 20 {
+		a := &Agent{Namespace: "syn", health: newHealth(0, 0)}
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		var returned atomic.Bool
+		late := make(chan struct{}, 1)
+		require.NoError(t, a.runLeaderElection(ctx, fake.NewClientset(), func(_, _ context.Context) {
+			if returned.Load() {
+				late <- struct{}{}
+			}
+		}))
+		returned.Store(true)
+		select {
+		case <-late:
+			t.Fatal("sync loop started after the leader election returned")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func waitFor[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	var zero T
+	return zero
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+// syncCluster sends the cluster properties to Lieutenant and returns the cluster object.
+// The cluster object is only updated if the properties changed since the last successful update
+// or if the last update is older than the force sync interval.
+func (a *Agent) syncCluster(ctx context.Context, apiClient *api.Client, props api.ClusterProperties) (*api.Cluster, error) {
+	body, hash, err := encodeClusterProperties(props)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash == a.lastSyncedHash && time.Since(a.lastFullSync) < a.forceSyncInterval() {
+		// Only fetch the cluster to keep the Argo CD configuration up to date
+		return a.getCluster(ctx, apiClient)
+	}
+
+	cluster, err := a.updateCluster(ctx, apiClient, body)
+	if err != nil {
+		return nil, err
+	}
+	a.lastSyncedHash = hash
+	a.lastFullSync = time.Now()
+	return cluster, nil
+}
+
+// updateCluster patches the cluster object with the given properties and returns the updated cluster
+func (a *Agent) updateCluster(ctx context.Context, apiClient *api.Client, patch []byte) (*api.Cluster, error) {
+	start := time.Now()
+	resp, err := apiClient.UpdateClusterWithBody(ctx, api.ClusterIdParameter(a.ClusterID), api.ContentJSONPatch, bytes.NewReader(patch))
+	observeLieutenantRequest("update", start, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	return decodeClusterResponse(resp)
+}
+
+// getCluster fetches the cluster object without modifying it
+func (a *Agent) getCluster(ctx context.Context, apiClient *api.Client) (*api.Cluster, error) {
+	start := time.Now()
+	resp, err := apiClient.GetCluster(ctx, api.ClusterIdParameter(a.ClusterID))
+	observeLieutenantRequest("get", start, resp, err)
+	if err != nil {
+		return nil, err
+	}
+	return decodeClusterResponse(resp)
+}
+
+func observeLieutenantRequest(operation string, start time.Time, resp *http.Response, err error) {
+	metrics.LieutenantRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	metrics.LieutenantRequests.WithLabelValues(operation, metrics.StatusCode(resp, err)).Inc()
+}
+
+func decodeClusterResponse(resp *http.Response) (*api.Cluster, error) {
+	defer resp.Body.Close()
+	defer io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		reason := &api.Reason{}
+		if err := json.NewDecoder(resp.Body).Decode(reason); err == nil {
+			apiErr.Reason = reason.Reason
+		}
+		return nil, apiErr
+	}
+	cluster := &api.Cluster{}
+	if err := json.NewDecoder(resp.Body).Decode(cluster); err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// encodeClusterProperties returns the JSON encoded properties and their hash.
+// The encoding is deterministic, map keys are sorted.
+func encodeClusterProperties(props api.ClusterProperties) ([]byte, string, error) {
+	body, err := json.Marshal(props)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(body)
+	return body, hex.EncodeToString(sum[:]), nil
+}
+
+// APIError is returned if the Lieutenant API responds with an unexpected status
+type APIError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *APIError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("unexpected status %d from Lieutenant API", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status %d from Lieutenant API: %s", e.StatusCode, e.Reason)
+}
+
+// isRetryable returns false for errors which won't go away by retrying soon,
+// like an invalid token or a deleted cluster. All other errors, including network errors, are retryable.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	return apiErr.StatusCode >= 500 ||
+		apiErr.StatusCode == http.StatusTooManyRequests ||
+		apiErr.StatusCode == http.StatusRequestTimeout
+}
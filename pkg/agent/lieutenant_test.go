@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+// fakeLieutenant records the requests to the cluster endpoint and responds with the configured status
+type fakeLieutenant struct {
+	status  int
+	methods []string
+	bodies  []string
+}
+
+func (f *fakeLieutenant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.methods = append(f.methods, r.Method)
+	f.bodies = append(f.bodies, string(body))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(f.status)
+	if f.status != http.StatusOK {
+		_ = json.NewEncoder(w).Encode(api.Reason{Reason: "failed"})
+		return
+	}
+	url := "ssh://git@git.example.com/catalog.git"
+	_ = json.NewEncoder(w).Encode(api.Cluster{ClusterProperties: api.ClusterProperties{GitRepo: &api.GitRepo{Url: &url}}})
+}
+
+func newFakeLieutenant(t *testing.T) (*fakeLieutenant, *api.Client) {
+	lieutenant := &fakeLieutenant{status: http.StatusOK}
+	srv := httptest.NewServer(lieutenant)
+	t.Cleanup(srv.Close)
+	apiClient, err := api.NewClient(srv.URL)
+	require.NoError(t, err)
+	return lieutenant, apiClient
+}
+
+func props(facts api.DynamicClusterFacts) api.ClusterProperties {
+	key := "ssh-ed25519 AAAA"
+	return api.ClusterProperties{GitRepo: &api.GitRepo{DeployKey: &key}, DynamicFacts: &facts}
+}
+
+func TestSyncClusterSkipsUnchanged(t *testing.T) {
+	lieutenant, apiClient := newFakeLieutenant(t)
+	a := &Agent{ClusterID: "c-cluster"}
+
+	cluster, err := a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1", "b": "2"}))
+	require.NoError(t, err)
+	assert.Equal(t, "ssh://git@git.example.com/catalog.git", *cluster.GitRepo.Url)
+
+	// Same facts in different order
+	cluster, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"b": "2", "a": "1"}))
+	require.NoError(t, err)
+	assert.Equal(t, "ssh://git@git.example.com/catalog.git", *cluster.GitRepo.Url)
+
+	_, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1", "b": "3"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{http.MethodPatch, http.MethodGet, http.MethodPatch}, lieutenant.methods)
+	assert.JSONEq(t, `{"gitRepo":{"deployKey":"ssh-ed25519 AAAA"},"dynamicFacts":{"a":"1","b":"3"}}`, lieutenant.bodies[2])
+}
+
+func TestSyncClusterForcesUpdate(t *testing.T) {
+	lieutenant, apiClient := newFakeLieutenant(t)
+	a := &Agent{ClusterID: "c-cluster", ForceSyncInterval: time.Hour}
+
+	_, err := a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1"}))
+	require.NoError(t, err)
+	a.lastFullSync = time.Now().Add(-2 * time.Hour)
+	_, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{http.MethodPatch, http.MethodPatch}, lieutenant.methods)
+}
+
+func TestSyncClusterRetriesFailedUpdate(t *testing.T) {
+	lieutenant, apiClient := newFakeLieutenant(t)
+	a := &Agent{ClusterID: "c-cluster"}
+
+	lieutenant.status = http.StatusInternalServerError
+	_, err := a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1"}))
+	assert.ErrorContains(t, err, "failed")
+
+	lieutenant.status = http.StatusOK
+	_, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{http.MethodPatch, http.MethodPatch}, lieutenant.methods)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tcs := map[string]struct {
+		err       error
+		retryable bool
+	}{
+		"network":           {err: errors.New("dial tcp: connection refused"), retryable: true},
+		"internal error":    {err: &APIError{StatusCode: http.StatusInternalServerError}, retryable: true},
+		"bad gateway":       {err: &APIError{StatusCode: http.StatusBadGateway}, retryable: true},
+		"rate limited":      {err: &APIError{StatusCode: http.StatusTooManyRequests}, retryable: true},
+		"wrapped":           {err: fmt.Errorf("sync: %w", &APIError{StatusCode: http.StatusServiceUnavailable}), retryable: true},
+		"unauthorized":      {err: &APIError{StatusCode: http.StatusUnauthorized}, retryable: false},
+		"forbidden":         {err: &APIError{StatusCode: http.StatusForbidden}, retryable: false},
+		"not found":         {err: &APIError{StatusCode: http.StatusNotFound}, retryable: false},
+		"wrapped forbidden": {err: fmt.Errorf("sync: %w", &APIError{StatusCode: http.StatusForbidden}), retryable: false},
+	}
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			assert.Equal(t, tc.retryable, isRetryable(tc.err))
+		})
+	}
+}
+
+func TestSyncClusterReturnsAPIError(t *testing.T) {
+	lieutenant, apiClient := newFakeLieutenant(t)
+	a := &Agent{ClusterID: "c-cluster"}
+
+	lieutenant.status = http.StatusForbidden
+	_, err := a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{}))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "failed", apiErr.Reason)
+}
+
+func TestSyncClusterMetrics(t *testing.T) {
+	lieutenant, apiClient := newFakeLieutenant(t)
+	a := &Agent{ClusterID: "c-cluster"}
+
+	updates := testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("update", "200"))
+	gets := testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("get", "200"))
+	failures := testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("update", "503"))
+
+	_, err := a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{}))
+	require.NoError(t, err)
+	_, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{}))
+	require.NoError(t, err)
+	lieutenant.status = http.StatusServiceUnavailable
+	_, err = a.syncCluster(t.Context(), apiClient, props(api.DynamicClusterFacts{"a": "1"}))
+	require.Error(t, err)
+
+	assert.Equal(t, updates+1, testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("update", "200")))
+	assert.Equal(t, gets+1, testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("get", "200")))
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.LieutenantRequests.WithLabelValues("update", "503")))
+}
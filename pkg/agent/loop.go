@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+const (
+	defaultRetryBackoff    = 10 * time.Second
+	defaultMaxRetryBackoff = 5 * time.Minute
+	// defaultShutdownGracePeriod leaves some time of the default pod termination grace period of 30s to release the lease
+	defaultShutdownGracePeriod = 20 * time.Second
+	// resyncJitter spreads the resyncs of a fleet of clusters started at the same time
+	resyncJitter = 0.1
+	// retryJitter spreads the retries of a fleet of clusters failing at the same time
+	retryJitter = 0.5
+)
+
+// runSyncLoop calls sync after a random initial delay, on every resync and after the fact sources changed.
+// Failed syncs are retried with a jittered exponential backoff, unless the error isn't retryable.
+// Changes of the fact sources don't trigger a sync while syncs are failing.
+// A sync running when the context is done gets the shutdown grace period to finish before it's cancelled,
+// unless the lease context is done as well, then another replica may already be syncing and the sync is aborted at once.
+func (a *Agent) runSyncLoop(ctx, lease context.Context, changes <-chan struct{}, sync func(context.Context) error) {
+	backoff := a.newRetryBackoff()
+	failing := false
+
+	timer := time.NewTimer(randomDelay(a.InitialSyncJitter))
+	defer timer.Stop()
+	run := func() {
+		syncCtx, cancel := shutdownGraceContext(ctx, lease, cmp.Or(a.ShutdownGracePeriod, defaultShutdownGracePeriod))
+		err := sync(syncCtx)
+		cancel()
+		// The sync may have changed the resync interval with a StewardConfig
+		resyncInterval := a.resyncInterval()
+		metrics.Syncs.WithLabelValues(metrics.Result(err)).Inc()
+		switch {
+		case err == nil:
+			metrics.LastSuccessfulSync.SetToCurrentTime()
+			failing = false
+			backoff = a.newRetryBackoff()
+			timer.Reset(wait.Jitter(resyncInterval, resyncJitter))
+		case isRetryable(err):
+			failing = true
+			delay := backoff.Step()
+			klog.FromContext(ctx).Error(err, "Sync failed, retrying", "retryIn", delay.Round(time.Second))
+			timer.Reset(delay)
+		default:
+			failing = true
+			klog.FromContext(ctx).Error(err, "Sync failed with a permanent error, retrying at the next resync", "retryIn", resyncInterval)
+			timer.Reset(resyncInterval)
+		}
+	}
+
+	heartbeat := time.NewTicker(loopHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		a.health.beat()
+		select {
+		case <-heartbeat.C:
+		case <-timer.C:
+			run()
+		case <-changes:
+			if debounce == nil && !failing {
+				debounce = time.After(factChangeDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			if failing {
+				continue
+			}
+			klog.FromContext(ctx).Info("Fact sources changed, syncing")
+			timer.Stop()
+			run()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// shutdownGraceContext returns a context which is cancelled the grace period after the parent is done,
+// or as soon as the lease is done
+func shutdownGraceContext(parent, lease context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stopLease := context.AfterFunc(lease, func() {
+		klog.FromContext(parent).Info("Lost the lease, aborting the running sync")
+		cancel()
+	})
+	stop := context.AfterFunc(parent, func() {
+		if lease.Err() != nil {
+			return
+		}
+		klog.FromContext(parent).Info("Shutting down, waiting for the running sync to finish", "gracePeriod", grace)
+		timer := time.AfterFunc(grace, func() {
+			klog.FromContext(parent).Info("Shutdown grace period expired, aborting the running sync")
+			cancel()
+		})
+		context.AfterFunc(ctx, func() { timer.Stop() })
+	})
+	return ctx, func() {
+		stop()
+		stopLease()
+		cancel()
+	}
+}
+
+func (a *Agent) newRetryBackoff() *wait.Backoff {
+	base, maxDelay := a.RetryBackoff, a.MaxRetryBackoff
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxRetryBackoff
+	}
+	// The jitter is added after the cap, lower the cap so the delays don't exceed the max
+	maxDelay = time.Duration(float64(maxDelay) / (1 + retryJitter))
+	return &wait.Backoff{
+		Duration: min(base, maxDelay),
+		Factor:   2,
+		Jitter:   retryJitter,
+		Steps:    math.MaxInt32,
+		Cap:      maxDelay,
+	}
+}
+
+// randomDelay returns a random duration between 0 and max
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
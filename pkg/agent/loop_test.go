@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingSync returns the errors in order and cancels the context once all of them were returned
+func countingSync(cancel context.CancelFunc, errs ...error) (func(context.Context) error, *[]time.Time) {
+	calls := []time.Time{}
+	return func(context.Context) error {
+		calls = append(calls, time.Now())
+		if len(calls) >= len(errs) {
+			cancel()
+			return errs[len(errs)-1]
+		}
+		return errs[len(calls)-1]
+	}, &calls
+}
+
+func TestRunSyncLoopRetriesWithBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	a := &Agent{ResyncInterval: time.Hour, RetryBackoff: 10 * time.Millisecond, MaxRetryBackoff: 20 * time.Millisecond}
+
+	retryable := &APIError{StatusCode: http.StatusBadGateway}
+	sync, calls := countingSync(cancel, retryable, errors.New("connection refused"), retryable, retryable, nil)
+	a.runSyncLoop(ctx, context.Background(), nil, sync)
+
+	assert.Len(t, *calls, 5)
+	assert.NotErrorIs(t, ctx.Err(), context.DeadlineExceeded, "retries took too long")
+	for i := 1; i < len(*calls); i++ {
+		delay := (*calls)[i].Sub((*calls)[i-1])
+		assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+	}
+}
+
+func TestRunSyncLoopPermanentError(t *testing.T) {
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	a := &Agent{ResyncInterval: 200 * time.Millisecond, RetryBackoff: time.Millisecond}
+
+	sync, calls := countingSync(cancel, &APIError{StatusCode: http.StatusUnauthorized}, nil)
+	a.runSyncLoop(ctx, context.Background(), nil, sync)
+
+	assert.Len(t, *calls, 2)
+	assert.GreaterOrEqual(t, (*calls)[1].Sub((*calls)[0]), 200*time.Millisecond, "permanent errors must not be retried with backoff")
+}
+
+func TestRandomDelay(t *testing.T) {
+	assert.Zero(t, randomDelay(0))
+	for range 100 {
+		d := randomDelay(time.Second)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+}
+
+func TestShutdownGraceContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(t.Context())
+	ctx, cancel := shutdownGraceContext(parent, context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	cancelParent()
+	assert.NoError(t, ctx.Err(), "the grace period must not end with the parent")
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context wasn't cancelled after the grace period")
+	}
+
+	ctx, cancel = shutdownGraceContext(t.Context(), context.Background(), time.Hour)
+	cancel()
+	assert.Error(t, ctx.Err())
+}
+
+func TestRunSyncLoopFinishesSyncOnShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	a := &Agent{ShutdownGracePeriod: 5 * time.Second}
+
+	var syncErr error
+	calls := 0
+	a.runSyncLoop(ctx, context.Background(), nil, func(syncCtx context.Context) error {
+		calls++
+		cancel()
+		// The sync keeps running after shutdown was requested
+		time.Sleep(20 * time.Millisecond)
+		syncErr = syncCtx.Err()
+		return nil
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, syncErr)
+}
+
+func TestRunSyncLoopAbortsSyncOnLeaseLoss(t *testing.T) {
+	lease, loseLease := context.WithCancel(t.Context())
+	// Like the leader election, the loop context is done with the lease
+	ctx, cancel := context.WithCancel(lease)
+	defer cancel()
+	a := &Agent{ShutdownGracePeriod: time.Hour}
+
+	var syncErr error
+	var aborted time.Duration
+	a.runSyncLoop(ctx, lease, nil, func(syncCtx context.Context) error {
+		start := time.Now()
+		loseLease()
+		select {
+		case <-syncCtx.Done():
+		case <-time.After(5 * time.Second):
+		}
+		aborted = time.Since(start)
+		syncErr = syncCtx.Err()
+		return syncErr
+	})
+
+	assert.ErrorIs(t, syncErr, context.Canceled)
+	assert.Less(t, aborted, time.Second, "the sync must not get the shutdown grace period")
+}
+
+func TestNewRetryBackoffStaysBelowMax(t *testing.T) {
+	a := &Agent{RetryBackoff: time.Second, MaxRetryBackoff: time.Minute}
+	backoff := a.newRetryBackoff()
+	for range 100 {
+		delay := backoff.Step()
+		assert.GreaterOrEqual(t, delay, time.Second)
+		assert.LessOrEqual(t, delay, time.Minute)
+	}
+
+	// A base above the max is lowered as well
+	a = &Agent{RetryBackoff: time.Hour, MaxRetryBackoff: time.Minute}
+	assert.LessOrEqual(t, a.newRetryBackoff().Step(), time.Minute)
+}
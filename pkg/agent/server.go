@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// serveHTTP serves the handler on the address until the context is done.
+// The server is disabled if the address is empty or "0".
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler) error {
+	if addr == "" || addr == "0" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s for the %s endpoint: %w", addr, name, err)
+	}
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			klog.FromContext(ctx).Error(err, "Error shutting down endpoint", "endpoint", name)
+		}
+	}()
+	klog.FromContext(ctx).Info("Serving endpoint", "endpoint", name, "address", listener.Addr().String())
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			klog.FromContext(ctx).Error(err, "Error serving endpoint", "endpoint", name)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+	"github.com/projectsyn/steward/pkg/events"
+)
+
+const (
+	statusFieldManager = "syn.tools/steward"
+	statusWriteTimeout = 10 * time.Second
+)
+
+// syncStatus is published in the status ConfigMap after every sync
+type syncStatus struct {
+	LastSync           time.Time
+	LastSuccessfulSync time.Time
+	LastError          string
+	// FactsHash is the hash of the cluster properties last sent to Lieutenant
+	FactsHash   string
+	ArgoCDState argocd.State
+}
+
+func (s syncStatus) data() map[string]string {
+	data := map[string]string{
+		"lastSyncTime":           formatTime(s.LastSync),
+		"lastSuccessfulSyncTime": formatTime(s.LastSuccessfulSync),
+		"lastError":              s.LastError,
+		"factsHash":              s.FactsHash,
+		"argoCDState":            string(s.ArgoCDState),
+	}
+	if s.ArgoCDState == "" {
+		data["argoCDState"] = string(argocd.StateUnknown)
+	}
+	return data
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// recordSync records the outcome of a sync in the status ConfigMap and emits an event if the sync failed.
+// Failing to write the status doesn't fail the sync.
+func (a *Agent) recordSync(ctx context.Context, client kubernetes.Interface, syncErr error) {
+	now := time.Now()
+	a.status.LastSync = now
+	a.status.LastError = ""
+	if syncErr == nil {
+		a.status.LastSuccessfulSync = now
+	} else {
+		a.status.LastError = syncErr.Error()
+		events.Warning(syncFailureReason(syncErr), "Sync failed: %v", syncErr)
+	}
+	a.status.FactsHash = a.lastSyncedHash
+
+	if a.StatusConfigMap == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, statusWriteTimeout)
+	defer cancel()
+	cm := corev1.ConfigMap(a.StatusConfigMap, a.Namespace).
+		WithLabels(map[string]string{"app.kubernetes.io/managed-by": "steward"}).
+		WithData(a.status.data())
+	_, err := client.CoreV1().ConfigMaps(a.Namespace).Apply(ctx, cm, metav1.ApplyOptions{FieldManager: statusFieldManager, Force: true})
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Unable to update status", "kind", "ConfigMap", "name", a.StatusConfigMap)
+	}
+}
+
+// syncFailureReason returns the event reason for a failed sync
+func syncFailureReason(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return events.ReasonLieutenantRequestError
+	}
+	return events.ReasonSyncFailed
+}
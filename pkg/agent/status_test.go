@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+	"github.com/projectsyn/steward/pkg/events"
+)
+
+func TestRecordSync(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	events.SetRecorder(rec, nil)
+	t.Cleanup(func() { events.SetRecorder(nil, nil) })
+
+	client := fake.NewClientset()
+	a := &Agent{Namespace: "syn", StatusConfigMap: "steward-status", lastSyncedHash: "abc"}
+	a.status.ArgoCDState = argocd.StateRunning
+
+	a.recordSync(t.Context(), client, nil)
+	cm, err := client.CoreV1().ConfigMaps("syn").Get(t.Context(), "steward-status", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", cm.Data["factsHash"])
+	assert.Equal(t, "Running", cm.Data["argoCDState"])
+	assert.Empty(t, cm.Data["lastError"])
+	assert.NotEmpty(t, cm.Data["lastSyncTime"])
+	assert.Equal(t, cm.Data["lastSyncTime"], cm.Data["lastSuccessfulSyncTime"])
+	assert.Empty(t, rec.Events)
+
+	lastSuccess := a.status.LastSuccessfulSync
+	a.recordSync(t.Context(), client, &APIError{StatusCode: 401, Reason: "invalid token"})
+	cm, err = client.CoreV1().ConfigMaps("syn").Get(t.Context(), "steward-status", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "unexpected status 401 from Lieutenant API: invalid token", cm.Data["lastError"])
+	assert.Equal(t, lastSuccess, a.status.LastSuccessfulSync)
+	require.Len(t, rec.Events, 1)
+	assert.Equal(t, "Warning LieutenantRequestFailed Sync failed: unexpected status 401 from Lieutenant API: invalid token", <-rec.Events)
+
+	a.recordSync(t.Context(), client, errors.New("connection refused"))
+	require.Len(t, rec.Events, 1)
+	assert.Equal(t, "Warning SyncFailed Sync failed: connection refused", <-rec.Events)
+}
+
+func TestRecordSyncDisabled(t *testing.T) {
+	client := fake.NewClientset()
+	a := &Agent{Namespace: "syn"}
+
+	a.recordSync(t.Context(), client, nil)
+	cms, err := client.CoreV1().ConfigMaps("syn").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, cms.Items)
+	assert.False(t, a.status.LastSuccessfulSync.IsZero())
+}
+
+func TestSyncStatusData(t *testing.T) {
+	data := syncStatus{LastSync: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))}.data()
+	assert.Equal(t, map[string]string{
+		"lastSyncTime":           "2024-05-01T10:00:00Z",
+		"lastSuccessfulSyncTime": "",
+		"lastError":              "",
+		"factsHash":              "",
+		"argoCDState":            "Unknown",
+	}, data)
+}
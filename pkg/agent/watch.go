@@ -0,0 +1,147 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/stewardconfig"
+)
+
+var (
+	clusterVersionGVR = schema.GroupVersionResource{Group: "config.openshift.io", Version: "v1", Resource: "clusterversions"}
+	routeGVR          = schema.GroupVersionResource{Group: "route.openshift.io", Version: "v1", Resource: "routes"}
+)
+
+// watchFactSources starts informers on the resources the dynamic facts are based on:
+// the additional facts ConfigMap, the nodes and, on OpenShift, the ClusterVersion and the OAuth route.
+// The StewardConfig is watched as well, so changes of the settings are applied right away.
+// The returned channel receives a value whenever one of them changes.
+// The informers are stopped when the context is done.
+func (a *Agent) watchFactSources(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface) (<-chan struct{}, error) {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+			// A change is already pending
+		}
+	}
+
+	informers := []cache.SharedIndexInformer{}
+	nodeInformer := coreinformers.NewNodeInformer(client, 0, cache.Indexers{})
+	if _, err := nodeInformer.AddEventHandler(changeHandler(notify, func(oldObj, newObj interface{}) bool {
+		oldNode, oldOK := oldObj.(*corev1.Node)
+		newNode, newOK := newObj.(*corev1.Node)
+		return !oldOK || !newOK || nodeFactsChanged(oldNode, newNode)
+	})); err != nil {
+		return nil, err
+	}
+	informers = append(informers, nodeInformer)
+
+	if a.AdditionalFactsConfigMap != "" {
+		cmInformer := coreinformers.NewFilteredConfigMapInformer(client, a.Namespace, 0, cache.Indexers{}, nameSelector(a.AdditionalFactsConfigMap))
+		if _, err := cmInformer.AddEventHandler(changeHandler(notify, nil)); err != nil {
+			return nil, err
+		}
+		informers = append(informers, cmInformer)
+	}
+
+	type dynamicSource struct {
+		gvr       schema.GroupVersionResource
+		namespace string
+		name      string
+	}
+	dynamicSources := []dynamicSource{
+		{clusterVersionGVR, "", "version"},
+		{routeGVR, a.OCPOAuthRouteNamespace, a.OCPOAuthRouteName},
+	}
+	if a.ConfigName != "" {
+		dynamicSources = append(dynamicSources, dynamicSource{stewardconfig.GVR, a.Namespace, a.ConfigName})
+	}
+	for _, src := range dynamicSources {
+		served, err := resourceServed(client, src.gvr)
+		if err != nil {
+			return nil, err
+		}
+		if !served {
+			continue
+		}
+		inf := dynamicinformer.NewFilteredDynamicInformer(dynamicClient, src.gvr, src.namespace, 0, cache.Indexers{}, nameSelector(src.name)).Informer()
+		if _, err := inf.AddEventHandler(changeHandler(notify, nil)); err != nil {
+			return nil, err
+		}
+		informers = append(informers, inf)
+	}
+
+	for _, inf := range informers {
+		go inf.RunWithContext(ctx)
+	}
+	klog.FromContext(ctx).Info("Watching fact sources for changes", "sources", len(informers))
+	return changes, nil
+}
+
+// changeHandler calls notify on every change except for the objects listed initially.
+// If changed is set, updates are only reported if it returns true.
+func changeHandler(notify func(), changed func(oldObj, newObj interface{}) bool) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(_ interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				notify()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if changed == nil || changed(oldObj, newObj) {
+				notify()
+			}
+		},
+		DeleteFunc: func(interface{}) {
+			notify()
+		},
+	}
+}
+
+// nodeFactsChanged returns true if a node field the dynamic facts are based on changed.
+// Nodes are updated frequently with status changes which don't affect any facts.
+func nodeFactsChanged(oldNode, newNode *corev1.Node) bool {
+	return !equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+		oldNode.Spec.ProviderID != newNode.Spec.ProviderID ||
+		!equality.Semantic.DeepEqual(oldNode.Status.NodeInfo, newNode.Status.NodeInfo) ||
+		!equality.Semantic.DeepEqual(oldNode.Status.Capacity, newNode.Status.Capacity) ||
+		!equality.Semantic.DeepEqual(oldNode.Status.Allocatable, newNode.Status.Allocatable)
+}
+
+func nameSelector(name string) func(*metav1.ListOptions) {
+	return func(o *metav1.ListOptions) {
+		o.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+	}
+}
+
+// resourceServed checks whether the API server knows the resource
+func resourceServed(client kubernetes.Interface, gvr schema.GroupVersionResource) (bool, error) {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to discover %s: %w", gvr.GroupVersion(), err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == gvr.Resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeFactsChanged(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: map[string]string{"a": "b"}},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.31.1"},
+			Capacity: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+		},
+	}
+
+	tcs := map[string]struct {
+		modify  func(*corev1.Node)
+		changed bool
+	}{
+		"heartbeat": {
+			modify: func(n *corev1.Node) {
+				n.ResourceVersion = "2"
+				n.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, LastHeartbeatTime: metav1.Now()}}
+			},
+		},
+		"same quantity": {
+			modify: func(n *corev1.Node) {
+				n.Status.Capacity = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4000m")}
+			},
+		},
+		"labels": {
+			modify:  func(n *corev1.Node) { n.Labels = map[string]string{"a": "c"} },
+			changed: true,
+		},
+		"provider id": {
+			modify:  func(n *corev1.Node) { n.Spec.ProviderID = "aws:///i-0123" },
+			changed: true,
+		},
+		"kubelet": {
+			modify:  func(n *corev1.Node) { n.Status.NodeInfo.KubeletVersion = "v1.31.2" },
+			changed: true,
+		},
+		"capacity": {
+			modify: func(n *corev1.Node) {
+				n.Status.Capacity = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")}
+			},
+			changed: true,
+		},
+		"allocatable": {
+			modify: func(n *corev1.Node) {
+				n.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("3")}
+			},
+			changed: true,
+		},
+	}
+
+	for k, tc := range tcs {
+		t.Run(k, func(t *testing.T) {
+			updated := node.DeepCopy()
+			tc.modify(updated)
+			assert.Equal(t, tc.changed, nodeFactsChanged(node, updated))
+		})
+	}
+}
+
+func TestWatchFactSources(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "additional-facts", Namespace: "syn"}},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	a := Agent{Namespace: "syn", AdditionalFactsConfigMap: "additional-facts"}
+
+	changes, err := a.watchFactSources(t.Context(), client, dynamicClient)
+	require.NoError(t, err)
+
+	// The initially listed objects aren't reported as changes
+	assertNoChange(t, changes)
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "additional-facts", Namespace: "syn"},
+		Data:       map[string]string{"foo": "bar"},
+	}
+	_, err = client.CoreV1().ConfigMaps("syn").Update(t.Context(), cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assertChange(t, changes)
+
+	_, err = client.CoreV1().Nodes().Create(t.Context(), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "new"}}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assertChange(t, changes)
+}
+
+func assertChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a change notification")
+	}
+}
+
+func assertNoChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+		t.Fatal("expected no change notification")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
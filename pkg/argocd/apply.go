@@ -0,0 +1,91 @@
+package argocd
+
+import (
+	"context"
+	"strings"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// argoTrackingAnnotation is set by Argo CD on the objects it syncs with the annotation tracking method
+	argoTrackingAnnotation = "argocd.argoproj.io/tracking-id"
+	// argoInstanceLabel is set by Argo CD on the objects it syncs with the label tracking method configured in argocd-cm.
+	// steward sets it on its bootstrapped components as well, so the catalog adopts them.
+	argoInstanceLabel = "argocd.argoproj.io/instance"
+	// argoBootstrapLabel marks the components bootstrapped by steward
+	argoBootstrapLabel = "steward.syn.tools/bootstrap"
+	// argoManagerPrefix matches the field managers of Argo CD and the Argo CD operator
+	argoManagerPrefix = "argocd"
+)
+
+// applyConfiguration is the apply configuration of an object.
+// It only holds the fields set by steward, so steward doesn't own any other fields of the object.
+type applyConfiguration interface {
+	GetKind() *string
+	GetName() *string
+}
+
+// applyClient is implemented by the typed clients of the objects reconciled by steward
+type applyClient[T metav1.Object, C applyConfiguration] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Apply(ctx context.Context, cfg C, opts metav1.ApplyOptions) (T, error)
+}
+
+// applyObject reconciles the object with server-side apply, which also corrects any drift of the fields set by steward.
+// Objects which were taken over by the Argo CD operator or by Argo CD itself are left alone.
+func applyObject[T metav1.Object, C applyConfiguration](ctx context.Context, client applyClient[T, C], cfg C) error {
+	name := ptr.Deref(cfg.GetName(), "")
+	log := klog.FromContext(ctx).WithValues("kind", ptr.Deref(cfg.GetKind(), ""), "name", name)
+
+	current, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !k8serr.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+	if exists {
+		if manager := takenOverBy(current); manager != "" {
+			log.V(1).Info("Argo CD object is managed by someone else, skipping", "manager", manager)
+			return nil
+		}
+	}
+
+	applied, err := client.Apply(ctx, cfg, forceApplyOpts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !exists:
+		log.Info("Created Argo CD object")
+	case applied.GetResourceVersion() != current.GetResourceVersion():
+		log.Info("Updated Argo CD object")
+	default:
+		log.V(1).Info("Argo CD object is up to date")
+	}
+	return nil
+}
+
+// takenOverBy returns who took over the management of the object from steward, or an empty string if nobody did.
+// The Argo CD operator sets itself as the owner of the objects it manages,
+// and Argo CD tracks the objects it syncs with a label or an annotation and its own field manager.
+// The instance label of the bootstrapped components is set by steward, Argo CD is only detected by its field manager on those.
+func takenOverBy(obj metav1.Object) string {
+	if refs := obj.GetOwnerReferences(); len(refs) > 0 {
+		return refs[0].Kind + "/" + refs[0].Name
+	}
+	if _, ok := obj.GetAnnotations()[argoTrackingAnnotation]; ok {
+		return "Argo CD"
+	}
+	if app, ok := obj.GetLabels()[argoInstanceLabel]; ok && obj.GetLabels()[argoBootstrapLabel] != "true" {
+		return "Argo CD app " + app
+	}
+	for _, mf := range obj.GetManagedFields() {
+		if mf.Subresource == "" && strings.HasPrefix(mf.Manager, argoManagerPrefix) {
+			return mf.Manager
+		}
+	}
+	return ""
+}
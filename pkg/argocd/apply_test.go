@@ -0,0 +1,114 @@
+package argocd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func getDeployment(t *testing.T, client *fake.Clientset, name string) *appsv1.Deployment {
+	t.Helper()
+	d, err := client.AppsV1().Deployments("syn").Get(t.Context(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return d
+}
+
+func TestApplyObjectCorrectsDrift(t *testing.T) {
+	client := fake.NewClientset()
+	require.NoError(t, applyServerDeployment(t.Context(), client, "syn", "argocd:v1", nil))
+	d := getDeployment(t, client, "argocd-server")
+	assert.Equal(t, "argocd:v1", d.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, "true", d.Labels["steward.syn.tools/bootstrap"])
+
+	d.Spec.Template.Spec.Containers[0].Image = "argocd:edited"
+	_, err := client.AppsV1().Deployments("syn").Update(t.Context(), d, metav1.UpdateOptions{FieldManager: "kubectl-edit"})
+	require.NoError(t, err)
+
+	require.NoError(t, applyServerDeployment(t.Context(), client, "syn", "argocd:v1", nil))
+	assert.Equal(t, "argocd:v1", getDeployment(t, client, "argocd-server").Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestApplyObjectOnlyOwnsSetFields(t *testing.T) {
+	client := fake.NewClientset()
+	require.NoError(t, applyServerDeployment(t.Context(), client, "syn", "argocd:v1", nil))
+
+	d := getDeployment(t, client, "argocd-server")
+	i := slices.IndexFunc(d.ManagedFields, func(mf metav1.ManagedFieldsEntry) bool { return mf.Manager == fieldManager })
+	require.GreaterOrEqual(t, i, 0)
+	fields := string(d.ManagedFields[i].FieldsV1.Raw)
+	assert.Contains(t, fields, `"f:image"`)
+	for _, unset := range []string{`"f:strategy"`, `"f:resources"`, `"f:status"`} {
+		assert.NotContains(t, fields, unset)
+	}
+}
+
+func TestApplyObjectSkipsTakenOverObjects(t *testing.T) {
+	tcs := map[string]func(*appsv1.Deployment){
+		"operator": func(d *appsv1.Deployment) {
+			d.OwnerReferences = []metav1.OwnerReference{{APIVersion: "argoproj.io/v1beta1", Kind: "ArgoCD", Name: "syn-argocd", UID: "1"}}
+		},
+		"argo cd": func(d *appsv1.Deployment) {
+			d.Annotations = map[string]string{argoTrackingAnnotation: "argocd:apps/Deployment:syn/argocd-server"}
+		},
+	}
+	for name, takeOver := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewClientset()
+			require.NoError(t, applyServerDeployment(t.Context(), client, "syn", "argocd:v1", nil))
+			d := getDeployment(t, client, "argocd-server")
+			takeOver(d)
+			d.Spec.Template.Spec.Containers[0].Image = "argocd:v2"
+			_, err := client.AppsV1().Deployments("syn").Update(t.Context(), d, metav1.UpdateOptions{})
+			require.NoError(t, err)
+
+			require.NoError(t, applyServerDeployment(t.Context(), client, "syn", "argocd:v1", nil))
+			assert.Equal(t, "argocd:v2", getDeployment(t, client, "argocd-server").Spec.Template.Spec.Containers[0].Image)
+		})
+	}
+}
+
+func TestTakenOverBy(t *testing.T) {
+	tcs := map[string]struct {
+		meta     metav1.ObjectMeta
+		expected string
+	}{
+		"steward": {
+			meta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, Subresource: "status"},
+			}},
+		},
+		"owner": {
+			meta:     metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "ArgoCD", Name: "syn-argocd"}}},
+			expected: "ArgoCD/syn-argocd",
+		},
+		"tracking annotation": {
+			meta:     metav1.ObjectMeta{Annotations: map[string]string{argoTrackingAnnotation: "argocd:apps/Deployment:syn/argocd-server"}},
+			expected: "Argo CD",
+		},
+		"tracking label": {
+			meta:     metav1.ObjectMeta{Labels: map[string]string{argoInstanceLabel: "argocd"}},
+			expected: "Argo CD app argocd",
+		},
+		"bootstrapped component": {
+			meta: metav1.ObjectMeta{Labels: argoLabels},
+		},
+		"argo cd field manager": {
+			meta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+				{Manager: "argocd-controller", Operation: metav1.ManagedFieldsOperationUpdate},
+			}},
+			expected: "argocd-controller",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, takenOverBy(&tc.meta))
+		})
+	}
+}
@@ -5,16 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"github.com/projectsyn/lieutenant-api/pkg/api"
 	"k8s.io/apimachinery/pkg/api/errors"
-	k8err "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 )
 
 var (
@@ -31,120 +29,87 @@ var (
 	additionalRootAppsConfigKey = "teams"
 )
 
-func readAdditionalRootAppsConfigMap(ctx context.Context, clientset *kubernetes.Clientset, namespace, additionalRootAppsConfigMapName string) ([]string, error) {
+// readAdditionalRootAppsConfigMap reads the additional root apps, each entry is either a team name or a RootApp object.
+// It also returns whether the ConfigMap exists.
+func readAdditionalRootAppsConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace, additionalRootAppsConfigMapName string) ([]RootApp, bool, error) {
 	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, additionalRootAppsConfigMapName, v1.GetOptions{})
 	if err != nil {
 		if errors.IsNotFound(err) {
-			klog.Info("Additional root apps config map not present")
-			return []string{}, nil
+			klog.FromContext(ctx).Info("Additional root apps config map not present", "name", additionalRootAppsConfigMapName)
+			return []RootApp{}, false, nil
 		} else {
-			return nil, fmt.Errorf("unable to fetch the additional root apps config map: %w", err)
+			return nil, false, fmt.Errorf("unable to fetch the additional root apps config map: %w", err)
 		}
 	}
 	teamsJson, ok := cm.Data[additionalRootAppsConfigKey]
 	if !ok {
-		return nil, fmt.Errorf("additional root apps ConfigMap doesn't have key %s", additionalRootAppsConfigKey)
+		return nil, true, fmt.Errorf("additional root apps ConfigMap doesn't have key %s", additionalRootAppsConfigKey)
 	}
-	var teams []string
+	var teams []RootApp
 	if err := json.Unmarshal([]byte(teamsJson), &teams); err != nil {
-		return nil, fmt.Errorf("unmarshalling additional root apps ConfigMap contents: %v", err)
+		return nil, true, fmt.Errorf("unmarshalling additional root apps ConfigMap contents: %v", err)
 	}
-	return teams, nil
+	return teams, true, nil
 }
 
-func createArgoProject(ctx context.Context, cluster *api.Cluster, config *rest.Config, namespace, name string) error {
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return err
-	}
-	argoProjectClient := dynamicClient.Resource(argoProjectGVR)
+// applyArgoProject reconciles the Argo CD project with server-side apply
+func applyArgoProject(ctx context.Context, client dynamic.Interface, namespace, name string, labels map[string]string, spec map[string]interface{}) error {
+	return applyObject(ctx, dynamicApplyClient{client.Resource(argoProjectGVR).Namespace(namespace)}, unstructuredApplyConfiguration{argoObject("AppProject", name, labels, spec)})
+}
 
-	if _, err = argoProjectClient.Namespace(namespace).Get(ctx, name, v1.GetOptions{}); err == nil {
-		return nil
-	}
+// applyArgoApp reconciles the Argo CD application with server-side apply
+func applyArgoApp(ctx context.Context, client dynamic.Interface, namespace, name string, labels map[string]string, spec map[string]interface{}) error {
+	return applyObject(ctx, dynamicApplyClient{client.Resource(argoAppGVR).Namespace(namespace)}, unstructuredApplyConfiguration{argoObject("Application", name, labels, spec)})
+}
 
-	project := &unstructured.Unstructured{
+func argoObject(kind, name string, labels map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{
 		Object: map[string]interface{}{
-			"apiVersion": argoProjectGVR.Group + "/" + argoProjectGVR.Version,
-			"kind":       "AppProject",
+			"apiVersion": argoGroupVersion.String(),
+			"kind":       kind,
 			"metadata": map[string]interface{}{
 				"name": name,
 			},
-			"spec": map[string]interface{}{
-				"clusterResourceWhitelist": []map[string]interface{}{{
-					"group": "*",
-					"kind":  "*",
-				}},
-				"destinations": []map[string]interface{}{{
-					"namespace": "*",
-					"server":    localKubernetesAPI,
-				}},
-				"sourceRepos": []string{
-					*cluster.GitRepo.Url,
-				},
-			},
+			"spec": spec,
 		},
 	}
-
-	if _, err = argoProjectClient.Namespace(namespace).Create(ctx, project, createOpts); err != nil {
-		if k8err.IsAlreadyExists(err) {
-			klog.Warning("Argo Project already exists, skipping... app=", name)
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Argo Project created: ", name)
+	if len(labels) > 0 {
+		obj.SetLabels(labels)
 	}
-	return nil
+	return obj
+}
+
+// dynamicApplyClient adapts a dynamic client for the Argo CD objects to applyObject
+type dynamicApplyClient struct {
+	dynamic.ResourceInterface
 }
 
-func createArgoApp(ctx context.Context, cluster *api.Cluster, config *rest.Config, namespace, projectName, name, appsPath string) error {
-	dynamicClient, err := dynamic.NewForConfig(config)
+// Get returns the object without its managed fields.
+// Argo CD updates the status of its applications and projects without a subresource, so its field managers
+// don't mean it took them over. Objects synced by Argo CD are still recognized by the tracking annotation.
+func (c dynamicApplyClient) Get(ctx context.Context, name string, opts v1.GetOptions) (*unstructured.Unstructured, error) {
+	obj, err := c.ResourceInterface.Get(ctx, name, opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	argoAppClient := dynamicClient.Resource(argoAppGVR)
+	obj.SetManagedFields(nil)
+	return obj, nil
+}
 
-	if _, err = argoAppClient.Namespace(namespace).Get(ctx, name, v1.GetOptions{}); err == nil {
-		return nil
-	}
+// Apply applies the object with server-side apply
+func (c dynamicApplyClient) Apply(ctx context.Context, cfg unstructuredApplyConfiguration, opts v1.ApplyOptions) (*unstructured.Unstructured, error) {
+	return c.ResourceInterface.Apply(ctx, cfg.Unstructured.GetName(), cfg.Unstructured, opts)
+}
 
-	app := &unstructured.Unstructured{
-		Object: map[string]interface{}{
-			"apiVersion": argoAppGVR.Group + "/" + argoAppGVR.Version,
-			"kind":       "Application",
-			"metadata": map[string]interface{}{
-				"name": name,
-			},
-			"spec": map[string]interface{}{
-				"project": projectName,
-				"source": map[string]interface{}{
-					"repoURL":        *cluster.GitRepo.Url,
-					"path":           appsPath + "/",
-					"targetRevision": "HEAD",
-				},
-				"syncPolicy": map[string]interface{}{
-					"automated": map[string]interface{}{
-						"prune":    false,
-						"selfHeal": true,
-					},
-				},
-				"destination": map[string]interface{}{
-					"namespace": namespace,
-					"server":    localKubernetesAPI,
-				},
-			},
-		},
-	}
+// unstructuredApplyConfiguration adapts an unstructured object to applyObject, it only holds the fields set by steward
+type unstructuredApplyConfiguration struct {
+	*unstructured.Unstructured
+}
 
-	if _, err = argoAppClient.Namespace(namespace).Create(ctx, app, createOpts); err != nil {
-		if k8err.IsAlreadyExists(err) {
-			klog.Warning("Argo App already exists, skipping... app=", name)
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Argo App created: ", name)
-	}
-	return nil
+func (c unstructuredApplyConfiguration) GetKind() *string {
+	return ptr.To(c.Unstructured.GetKind())
+}
+
+func (c unstructuredApplyConfiguration) GetName() *string {
+	return ptr.To(c.Unstructured.GetName())
 }
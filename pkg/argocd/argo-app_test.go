@@ -0,0 +1,133 @@
+package argocd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+// newArgoClient returns a dynamic client for the Argo CD objects.
+// Like the API server, it applies the spec and labels of server-side apply patches
+// and doesn't delete objects with the Argo CD resources finalizer at once.
+func newArgoClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		argoAppGVR:     "ApplicationList",
+		argoProjectGVR: "AppProjectList",
+	}, objects...)
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		applied := &unstructured.Unstructured{}
+		if err := applied.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		current, err := client.Tracker().Get(patch.GetResource(), patch.GetNamespace(), patch.GetName())
+		if k8err.IsNotFound(err) {
+			applied.SetNamespace(patch.GetNamespace())
+			return true, applied, client.Tracker().Create(patch.GetResource(), applied, patch.GetNamespace())
+		}
+		if err != nil {
+			return true, nil, err
+		}
+		obj := current.(*unstructured.Unstructured).DeepCopy()
+		obj.Object["spec"] = applied.Object["spec"]
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		maps.Copy(labels, applied.GetLabels())
+		obj.SetLabels(labels)
+		return true, obj, client.Tracker().Update(patch.GetResource(), obj, patch.GetNamespace())
+	})
+	client.PrependReactor("delete", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		del := action.(k8stesting.DeleteAction)
+		obj, err := client.Tracker().Get(del.GetResource(), del.GetNamespace(), del.GetName())
+		if err != nil {
+			return false, nil, nil
+		}
+		if slices.ContainsFunc(obj.(*unstructured.Unstructured).GetFinalizers(), func(f string) bool {
+			return strings.HasPrefix(f, argoResourcesFinalizer)
+		}) {
+			return true, nil, fmt.Errorf("%s %s would cascade delete its resources", del.GetResource().Resource, del.GetName())
+		}
+		return false, nil, nil
+	})
+	return client
+}
+
+func argoTestObject(kind, name, project string, labels, annotations map[string]string, finalizers ...string) *unstructured.Unstructured {
+	obj := argoObject(kind, name, labels, map[string]interface{}{"project": project})
+	obj.SetNamespace("syn")
+	obj.SetAnnotations(annotations)
+	obj.SetFinalizers(finalizers)
+	return obj
+}
+
+func TestApplyDefaultRootApp(t *testing.T) {
+	// Created with the previous catalog repository, Argo CD added its finalizer and updated the status
+	app := argoTestObject("Application", defaultArgoRootAppName, defaultArgoProjectName, nil, nil, argoResourcesFinalizer)
+	app.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply},
+		{Manager: "argocd-application-controller", Operation: metav1.ManagedFieldsOperationUpdate},
+	})
+	client := newArgoClient(app, argoTestObject("AppProject", defaultArgoProjectName, "", nil, nil))
+	cluster := catalogCluster()
+	cluster.GitRepo.Url = ptr.To("ssh://git@git.example.org/catalog.git")
+
+	require.NoError(t, applyDefaultRootApp(t.Context(), client, "syn", cluster))
+
+	app, err := client.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), defaultArgoRootAppName, metav1.GetOptions{})
+	require.NoError(t, err)
+	repoURL, _, _ := unstructured.NestedString(app.Object, "spec", "source", "repoURL")
+	assert.Equal(t, "ssh://git@git.example.org/catalog.git", repoURL)
+	assert.Equal(t, []string{argoResourcesFinalizer}, app.GetFinalizers())
+
+	project, err := client.Resource(argoProjectGVR).Namespace("syn").Get(t.Context(), defaultArgoProjectName, metav1.GetOptions{})
+	require.NoError(t, err)
+	sourceRepos, _, _ := unstructured.NestedStringSlice(project.Object, "spec", "sourceRepos")
+	assert.Equal(t, []string{"ssh://git@git.example.org/catalog.git"}, sourceRepos)
+}
+
+func TestApplyArgoAppTakenOver(t *testing.T) {
+	client := newArgoClient(argoTestObject("Application", "root-team-a", "team-a", nil, map[string]string{argoTrackingAnnotation: "root-team-a"}))
+
+	require.NoError(t, applyArgoApp(t.Context(), client, "syn", "root-team-a", nil, RootApp{Name: "team-a"}.appSpec(catalogCluster(), "syn")))
+
+	app, err := client.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), "root-team-a", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"project": "team-a"}, app.Object["spec"])
+}
+
+func TestApplyDefaultRootAppTrackedByLabel(t *testing.T) {
+	// The catalog manages the root app and the project, Argo CD strips the status of the managed fields
+	tracked := map[string]string{argoInstanceLabel: "argocd"}
+	client := newArgoClient(
+		argoTestObject("Application", defaultArgoRootAppName, "catalog", tracked, nil),
+		argoTestObject("AppProject", defaultArgoProjectName, "", tracked, nil),
+	)
+
+	require.NoError(t, applyDefaultRootApp(t.Context(), client, "syn", catalogCluster()))
+
+	app, err := client.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), defaultArgoRootAppName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"project": "catalog"}, app.Object["spec"])
+	project, err := client.Resource(argoProjectGVR).Namespace("syn").Get(t.Context(), defaultArgoProjectName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"project": ""}, project.Object["spec"])
+}
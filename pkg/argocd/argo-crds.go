@@ -4,28 +4,34 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"regexp"
 
 	k8err "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 
-	apixinstall "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
-	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apixv1ac "k8s.io/apiextensions-apiserver/pkg/client/applyconfiguration/apiextensions/v1"
 	apixv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
 
 	"github.com/projectsyn/steward/manifests"
 )
 
-func createArgoCRDs(ctx context.Context, config *rest.Config) error {
-	apixClient, err := apixv1client.NewForConfig(config)
-	if err != nil {
-		return err
-	}
+// argoVersionAnnotation records the Argo CD version of the CRDs applied by steward
+const argoVersionAnnotation = "steward.syn.tools/argocd-version"
+
+// argoCRDNames are the names of the embedded Argo CD CRDs
+var argoCRDNames = []string{"applications.argoproj.io", "appprojects.argoproj.io"}
 
-	apixinstall.Install(scheme.Scheme)
-	decode := scheme.Codecs.UniversalDeserializer().Decode
+// manifestVersionPattern matches the Argo CD version in the source url written to the header of the embedded manifests
+var manifestVersionPattern = regexp.MustCompile(`(?m)^# url: \S*/argo-cd/(v[^/\s]+)/`)
 
+// createArgoCRDs applies the embedded Argo CD CRDs with server-side apply.
+// Without upgrade only missing CRDs are created. Otherwise a CRD is only replaced by an embedded one of the same or a newer Argo CD version,
+// CRDs which were taken over by Argo CD or the Argo CD operator are left alone.
+func createArgoCRDs(ctx context.Context, client apixv1client.CustomResourceDefinitionsGetter, upgrade bool) error {
 	return fs.WalkDir(manifests.Manifests, ".", func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -39,23 +45,66 @@ func createArgoCRDs(ctx context.Context, config *rest.Config) error {
 			return err
 		}
 
-		obj, _, err := decode(bytes, nil, nil)
-		if err != nil {
-			return err
+		// The apply configuration only holds the fields of the manifest, steward doesn't own any defaulted fields
+		crd := &apixv1ac.CustomResourceDefinitionApplyConfiguration{}
+		if err := yaml.UnmarshalStrict(bytes, crd); err != nil {
+			return fmt.Errorf("Provided manifest is not a valid CRD: %s: %w", path, err)
 		}
-		if crd, ok := obj.(*apixv1.CustomResourceDefinition); ok {
-			if _, err = apixClient.CustomResourceDefinitions().Create(ctx, crd, createOpts); err != nil {
-				if k8err.IsAlreadyExists(err) {
-					klog.Infof("%s CRD already exists, skip", crd.Name)
-				} else {
-					return err
-				}
-			} else {
-				klog.Infof("%s CRD created", crd.Name)
-			}
-		} else {
+		if ptr.Deref(crd.Kind, "") != "CustomResourceDefinition" || ptr.Deref(crd.Name, "") == "" {
 			return fmt.Errorf("Provided manifest is not a valid CRD: %s", path)
 		}
-		return nil
+		match := manifestVersionPattern.FindSubmatch(bytes)
+		if match == nil {
+			return fmt.Errorf("Provided manifest has no Argo CD version: %s", path)
+		}
+		return applyArgoCRD(ctx, client, crd, string(match[1]), upgrade)
 	})
 }
+
+// applyArgoCRD applies the CRD of the given Argo CD version unless the existing CRD is of a newer version.
+// An existing CRD without a version is only replaced if steward created it, its version is unknown otherwise.
+// Without upgrade an existing CRD is never replaced.
+func applyArgoCRD(ctx context.Context, client apixv1client.CustomResourceDefinitionsGetter, crd *apixv1ac.CustomResourceDefinitionApplyConfiguration, argoVersion string, upgrade bool) error {
+	name := ptr.Deref(crd.Name, "")
+	log := klog.FromContext(ctx).WithValues("kind", "CustomResourceDefinition", "name", name)
+	embedded, err := version.ParseSemantic(argoVersion)
+	if err != nil {
+		return fmt.Errorf("invalid Argo CD version of CRD %s: %w", name, err)
+	}
+
+	existing, err := client.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !k8err.IsNotFound(err) {
+		return err
+	}
+	if err == nil && !upgrade {
+		log.V(1).Info("Argo CD CRD exists and upgrades are disabled, skipping", "embeddedVersion", argoVersion)
+		return nil
+	}
+	if err == nil {
+		v, ok := existing.Annotations[argoVersionAnnotation]
+		if !ok {
+			if !managedBySteward(existing) {
+				log.V(1).Info("Argo CD CRD of unknown version wasn't created by steward, skipping", "embeddedVersion", argoVersion)
+				return nil
+			}
+		} else if installed, err := version.ParseSemantic(v); err != nil {
+			log.Info("Ignoring invalid Argo CD version of CRD", "version", v)
+		} else if embedded.LessThan(installed) {
+			log.V(1).Info("Argo CD CRD is newer than the embedded one, skipping", "version", v, "embeddedVersion", argoVersion)
+			return nil
+		}
+	}
+
+	crd.WithAnnotations(map[string]string{argoVersionAnnotation: argoVersion})
+	return applyObject(klog.NewContext(ctx, klog.FromContext(ctx).WithValues("version", argoVersion)), client.CustomResourceDefinitions(), crd)
+}
+
+// managedBySteward returns true if steward manages any fields of the object
+func managedBySteward(obj metav1.Object) bool {
+	for _, mf := range obj.GetManagedFields() {
+		if mf.Manager == fieldManager {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package argocd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apixv1ac "k8s.io/apiextensions-apiserver/pkg/client/applyconfiguration/apiextensions/v1"
+	apixfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const applicationCRDName = "applications.argoproj.io"
+
+// newCRDClient returns a fake client which stores the objects of apply patches.
+// The field managed fake client can't handle CRDs as it has no schema for them.
+func newCRDClient(objects ...runtime.Object) *apixfake.Clientset {
+	client := apixfake.NewSimpleClientset(objects...) //nolint:staticcheck
+	client.PrependReactor("patch", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		crd := &apixv1.CustomResourceDefinition{}
+		if err := json.Unmarshal(patch.GetPatch(), crd); err != nil {
+			return true, nil, err
+		}
+		tracker := client.Tracker()
+		gvr := apixv1.SchemeGroupVersion.WithResource("customresourcedefinitions")
+		if _, err := tracker.Get(gvr, "", crd.Name); err != nil {
+			return true, crd, tracker.Create(gvr, crd, "")
+		}
+		return true, crd, tracker.Update(gvr, crd, "")
+	})
+	return client
+}
+
+func makeCRD(name string, annotations map[string]string) *apixv1.CustomResourceDefinition {
+	return &apixv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+	}
+}
+
+func crdVersion(t *testing.T, client *apixfake.Clientset, name string) string {
+	t.Helper()
+	crd, err := client.ApiextensionsV1().CustomResourceDefinitions().Get(t.Context(), name, metav1.GetOptions{})
+	require.NoError(t, err)
+	return crd.Annotations[argoVersionAnnotation]
+}
+
+func TestCreateArgoCRDs(t *testing.T) {
+	client := newCRDClient()
+	require.NoError(t, createArgoCRDs(t.Context(), client.ApiextensionsV1(), true))
+
+	for _, name := range argoCRDNames {
+		crd, err := client.ApiextensionsV1().CustomResourceDefinitions().Get(t.Context(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Regexp(t, `^v\d+\.\d+\.\d+`, crd.Annotations[argoVersionAnnotation])
+		assert.NotEmpty(t, crd.Spec.Versions)
+	}
+}
+
+func TestApplyArgoCRD(t *testing.T) {
+	tcs := map[string]struct {
+		existing *apixv1.CustomResourceDefinition
+		expected string
+	}{
+		"missing": {
+			expected: "v2.1.0",
+		},
+		"unversioned": {
+			existing: makeCRD(applicationCRDName, nil),
+			expected: "",
+		},
+		"unversioned created by steward": {
+			existing: func() *apixv1.CustomResourceDefinition {
+				crd := makeCRD(applicationCRDName, nil)
+				crd.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate}}
+				return crd
+			}(),
+			expected: "v2.1.0",
+		},
+		"older": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "v2.0.5"}),
+			expected: "v2.1.0",
+		},
+		"same": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "v2.1.0"}),
+			expected: "v2.1.0",
+		},
+		"newer": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "v2.2.0"}),
+			expected: "v2.2.0",
+		},
+		"newer pre-release": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "v2.2.0-rc1"}),
+			expected: "v2.2.0-rc1",
+		},
+		"invalid": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "latest"}),
+			expected: "v2.1.0",
+		},
+		"taken over": {
+			existing: makeCRD(applicationCRDName, map[string]string{argoTrackingAnnotation: "argocd:apiextensions.k8s.io/CustomResourceDefinition:applications.argoproj.io"}),
+			expected: "",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := newCRDClient()
+			if tc.existing != nil {
+				client = newCRDClient(tc.existing)
+			}
+			crd := apixv1ac.CustomResourceDefinition(applicationCRDName)
+			require.NoError(t, applyArgoCRD(t.Context(), client.ApiextensionsV1(), crd, "v2.1.0", true))
+			assert.Equal(t, tc.expected, crdVersion(t, client, applicationCRDName))
+		})
+	}
+}
+
+func TestCreateArgoCRDsWithoutUpgrade(t *testing.T) {
+	client := newCRDClient(makeCRD(applicationCRDName, map[string]string{argoVersionAnnotation: "v2.0.5"}))
+	require.NoError(t, createArgoCRDs(t.Context(), client.ApiextensionsV1(), false))
+
+	assert.Equal(t, "v2.0.5", crdVersion(t, client, applicationCRDName), "existing CRDs are only upgraded if upgrades are enabled")
+	assert.Regexp(t, `^v\d+\.\d+\.\d+`, crdVersion(t, client, "appprojects.argoproj.io"), "missing CRDs are created")
+}
+
+func TestApplyArgoCRDInvalidVersion(t *testing.T) {
+	err := applyArgoCRD(t.Context(), newCRDClient().ApiextensionsV1(), apixv1ac.CustomResourceDefinition(applicationCRDName), "latest", true)
+	assert.ErrorContains(t, err, "invalid Argo CD version")
+}
@@ -1,6 +1,7 @@
 package argocd
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"time"
@@ -10,53 +11,109 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	apixv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/metrics"
 )
 
 var (
 	argoLabels = map[string]string{
-		"app.kubernetes.io/part-of":   "argocd",
-		"argocd.argoproj.io/instance": "argocd",
-		"steward.syn.tools/bootstrap": "true",
+		"app.kubernetes.io/part-of": "argocd",
+		argoInstanceLabel:           "argocd",
+		argoBootstrapLabel:          "true",
 	}
 	argoAnnotations = map[string]string{
 		"argocd.argoproj.io/sync-options": "Prune=false",
 	}
-	argoSSHSecretName      = "argo-ssh-key"
-	argoSSHPublicKey       = "sshPublicKey"
-	argoSSHPrivateKey      = "sshPrivateKey"
-	argoSSHConfigMapName   = "argocd-ssh-known-hosts-cm"
-	argoTLSConfigMapName   = "argocd-tls-certs-cm"
-	argoRbacConfigMapName  = "argocd-rbac-cm"
-	argoConfigMapName      = "argocd-cm"
-	argoSecretName         = "argocd-secret"
-	argoClusterSecretName  = "syn-argocd-cluster"
-	argoRbacName           = "argocd-application-controller"
-	argoRepoSecretName     = "cluster-catalog"
-	defaultArgoRootAppName = "root"
-	defaultArgoProjectName = "syn"
-	argoAppsPathPrefix     = "manifests/apps"
-	fieldManager           = "syn.tools/steward"
-
-	applyOpts  = metav1.ApplyOptions{FieldManager: fieldManager}
-	createOpts = metav1.CreateOptions{FieldManager: fieldManager}
-	updateOpts = metav1.UpdateOptions{FieldManager: fieldManager}
+	argoSSHSecretName             = "argo-ssh-key"
+	argoSSHPublicKey              = "sshPublicKey"
+	argoSSHPrivateKey             = "sshPrivateKey"
+	argoSSHConfigMapName          = "argocd-ssh-known-hosts-cm"
+	argoTLSConfigMapName          = "argocd-tls-certs-cm"
+	argoRbacConfigMapName         = "argocd-rbac-cm"
+	argoConfigMapName             = "argocd-cm"
+	argoSecretName                = "argocd-secret"
+	argoClusterSecretName         = "syn-argocd-cluster"
+	argoRbacName                  = "argocd-application-controller"
+	argoRepoSecretName            = "cluster-catalog"
+	argoRedisName                 = "argocd-redis"
+	argoRepoServerName            = "argocd-repo-server"
+	argoServerName                = "argocd-server"
+	argoApplicationControllerName = "argocd-application-controller"
+	defaultArgoRootAppName        = "root"
+	defaultArgoProjectName        = "syn"
+	argoAppsPathPrefix            = "manifests/apps"
+	fieldManager                  = "syn.tools/steward"
+
+	applyOpts      = metav1.ApplyOptions{FieldManager: fieldManager}
+	forceApplyOpts = metav1.ApplyOptions{FieldManager: fieldManager, Force: true}
+	createOpts     = metav1.CreateOptions{FieldManager: fieldManager}
+	updateOpts     = metav1.UpdateOptions{FieldManager: fieldManager}
 )
 
-// Apply reconciles the Argo CD deployments
-func Apply(ctx context.Context, config *rest.Config, namespace, operatorNamespace, argoImage, redisArgoImage, additionalRootAppsConfigMapName string, cluster *api.Cluster) error {
+// State describes how Argo CD is found on the cluster after Apply
+type State string
+
+const (
+	// StateUnknown is returned if Apply failed before it could determine the state
+	StateUnknown State = "Unknown"
+	// StateOperatorManaged means an ArgoCD custom resource exists and the operator manages Argo CD
+	StateOperatorManaged State = "OperatorManaged"
+	// StateRunning means all Argo CD components exist
+	StateRunning State = "Running"
+	// StateBootstrapped means steward bootstrapped Argo CD
+	StateBootstrapped State = "Bootstrapped"
+	// StateBootstrapFailed means steward failed to bootstrap Argo CD
+	StateBootstrapFailed State = "BootstrapFailed"
+	// StateUpgraded means steward rolled out new Argo CD images
+	StateUpgraded State = "Upgraded"
+	// StateUpgradeFailed means the new Argo CD images didn't roll out
+	StateUpgradeFailed State = "UpgradeFailed"
+)
+
+// UpgradeOptions controls the upgrade of an already bootstrapped Argo CD
+type UpgradeOptions struct {
+	// Enabled rolls out changed images to the running Argo CD components.
+	// Without it the components keep the images they're running.
+	Enabled bool
+	// Timeout limits the time to wait for the new images to roll out
+	Timeout time.Duration
+}
+
+// RootAppOptions controls the additional root apps and their projects
+type RootAppOptions struct {
+	// ConfigMapName is the name of the ConfigMap listing the additional root apps
+	ConfigMapName string
+	// Apps are created in addition to the ones listed in the ConfigMap
+	Apps []RootApp
+	// Prune deletes the root apps and projects of teams which are no longer listed.
+	// Without it they're only deleted once their removal is confirmed with an annotation.
+	Prune bool
+}
+
+// Apply reconciles the Argo CD deployments and returns the state of Argo CD
+func Apply(ctx context.Context, config *rest.Config, namespace, operatorNamespace, argoImage, redisArgoImage string, rootApps RootAppOptions, cluster *api.Cluster, upgrade UpgradeOptions, tuning Tuning) (State, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		return err
+		return StateUnknown, err
 	}
 
 	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
-		return err
+		return StateUnknown, err
+	}
+
+	crdClient, err := apixv1client.NewForConfig(config)
+	if err != nil {
+		return StateUnknown, err
 	}
 
 	gvr := schema.GroupVersionResource{
@@ -65,28 +122,32 @@ func Apply(ctx context.Context, config *rest.Config, namespace, operatorNamespac
 		Resource: "argocds",
 	}
 
-	if err = applyAdditionalRootApps(ctx, clientset, config, namespace, additionalRootAppsConfigMapName, cluster); err != nil {
-		return err
+	if err = applyAdditionalRootApps(ctx, clientset, dynamicClient, namespace, rootApps, cluster); err != nil {
+		return StateUnknown, err
 	}
 
 	argos, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil && !errors.IsNotFound(err) {
-		return err
+		return StateUnknown, err
 	}
 	if err == nil && len(argos.Items) > 0 {
 		// An ArgoCD custom resource exists in our namespace
 		err = fixArgoOperatorDeadlock(ctx, clientset, config, namespace, operatorNamespace)
 		if err != nil {
-			return fmt.Errorf("could not fix argocd operator deadlock: %w", err)
+			return StateOperatorManaged, fmt.Errorf("could not fix argocd operator deadlock: %w", err)
 		}
-		return nil
+		// The operator doesn't manage the catalog repository, it's migrated like on clusters without the operator
+		if err := reconcileCatalogRepo(ctx, clientset, dynamicClient, namespace, cluster); err != nil {
+			return StateOperatorManaged, err
+		}
+		return StateOperatorManaged, nil
 	}
 
 	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: "app.kubernetes.io/part-of=argocd",
 	})
 	if err != nil {
-		return fmt.Errorf("Could not list ArgoCD deployments: %w", err)
+		return StateUnknown, fmt.Errorf("Could not list ArgoCD deployments: %w", err)
 	}
 	expectedDeploymentCount := 3
 	foundDeploymentCount := len(deployments.Items)
@@ -95,58 +156,213 @@ func Apply(ctx context.Context, config *rest.Config, namespace, operatorNamespac
 		LabelSelector: "app.kubernetes.io/part-of=argocd",
 	})
 	if err != nil {
-		return fmt.Errorf("Could not list ArgoCD statefulsets: %w", err)
+		return StateUnknown, fmt.Errorf("Could not list ArgoCD statefulsets: %w", err)
 	}
 	expectedStatefulSetCount := 1
 	foundStatefulSetCount := len(statefulsets.Items)
 
 	if foundDeploymentCount == expectedDeploymentCount && foundStatefulSetCount == expectedStatefulSetCount {
-		// Found expected deployments, found expected statefulsets, skip
-		return nil
+		if err := reconcileCatalogRepo(ctx, clientset, dynamicClient, namespace, cluster); err != nil {
+			return StateRunning, err
+		}
+		return reconcileArgo(ctx, clientset, crdClient, namespace, argoImage, redisArgoImage, upgrade, tuning)
 	}
 
-	klog.Infof("Found %d of expected %d deployments, found %d of expected %d statefulsets, bootstrapping now", foundDeploymentCount, expectedDeploymentCount, foundStatefulSetCount, expectedStatefulSetCount)
-	return bootstrapArgo(ctx, clientset, config, namespace, argoImage, redisArgoImage, cluster)
+	klog.FromContext(ctx).Info("Argo CD components missing, bootstrapping now", "deployments", foundDeploymentCount, "expectedDeployments", expectedDeploymentCount, "statefulSets", foundStatefulSetCount, "expectedStatefulSets", expectedStatefulSetCount)
+	err = bootstrapArgo(ctx, clientset, crdClient, dynamicClient, namespace, argoImage, redisArgoImage, cluster, upgrade, tuning)
+	metrics.ArgoCDBootstraps.WithLabelValues(metrics.Result(err)).Inc()
+	if err != nil {
+		events.Warning(events.ReasonArgoCDBootstrapFailed, "Failed to bootstrap Argo CD: %v", err)
+		return StateBootstrapFailed, err
+	}
+	events.Normal(events.ReasonArgoCDBootstrapped, "Bootstrapped Argo CD with image %s", argoImage)
+	return StateBootstrapped, nil
 }
 
-func bootstrapArgo(ctx context.Context, clientset *kubernetes.Clientset, config *rest.Config, namespace, argoImage, redisArgoImage string, cluster *api.Cluster) error {
-	if err := createArgoCDConfigMaps(ctx, cluster, clientset, namespace); err != nil {
-		return err
+func bootstrapArgo(ctx context.Context, clientset *kubernetes.Clientset, crdClient apixv1client.CustomResourceDefinitionsGetter, dynamicClient dynamic.Interface, namespace, argoImage, redisArgoImage string, cluster *api.Cluster, upgrade UpgradeOptions, tuning Tuning) error {
+	phases := []bootstrapPhase{
+		{name: "configmaps", run: func(ctx context.Context) error {
+			if err := createArgoCDConfigMaps(ctx, cluster, clientset, namespace); err != nil {
+				return err
+			}
+			return applyArgoConfigMap(ctx, clientset, namespace, tuning.Config)
+		}},
+		{name: "repo-secret", run: func(ctx context.Context) error {
+			return createRepoSecret(ctx, cluster, clientset, namespace)
+		}},
+		{
+			name: "crds",
+			// Existing CRDs are only upgraded if upgrades are enabled
+			run: func(ctx context.Context) error {
+				return createArgoCRDs(ctx, crdClient, upgrade.Enabled)
+			},
+			// The project and the root app can only be created once the CRDs are served
+			ready: func(ctx context.Context, timeout time.Duration) error {
+				return waitForCRDsEstablished(ctx, crdClient, timeout)
+			},
+			timeout: crdEstablishedTimeout,
+		},
+		{
+			name: "redis",
+			run: func(ctx context.Context) error {
+				return applyRedisDeployment(ctx, clientset, namespace, argoImage, redisArgoImage, tuning.Redis)
+			},
+			ready: func(ctx context.Context, timeout time.Duration) error {
+				return waitForDeploymentAvailable(ctx, clientset, namespace, argoRedisName, timeout)
+			},
+			timeout: componentAvailableTimeout,
+		},
+		{
+			name: "repo-server",
+			run: func(ctx context.Context) error {
+				return applyRepoServerDeployment(ctx, clientset, namespace, argoImage, tuning.RepoServer)
+			},
+			ready: func(ctx context.Context, timeout time.Duration) error {
+				return waitForDeploymentAvailable(ctx, clientset, namespace, argoRepoServerName, timeout)
+			},
+			timeout: componentAvailableTimeout,
+		},
+		{name: "server", run: func(ctx context.Context) error {
+			return applyServerDeployment(ctx, clientset, namespace, argoImage, tuning.Server)
+		}},
+		{name: "project", run: func(ctx context.Context) error {
+			return applyArgoProject(ctx, dynamicClient, namespace, defaultArgoProjectName, nil, defaultRootApp.projectSpec(cluster))
+		}},
+		{name: "root-app", run: func(ctx context.Context) error {
+			return applyArgoApp(ctx, dynamicClient, namespace, defaultArgoRootAppName, nil, defaultRootApp.appSpec(cluster, namespace))
+		}},
+		{name: "application-controller", run: func(ctx context.Context) error {
+			return applyApplicationControllerStatefulSet(ctx, clientset, namespace, argoImage, tuning.ApplicationController)
+		}},
 	}
+	return runBootstrapPhases(ctx, phases)
+}
 
-	if err := createRepoSecret(ctx, cluster, clientset, namespace); err != nil {
-		return err
+// reconcileArgo reconciles the components of a bootstrapped Argo CD.
+// If upgrades are enabled, the CRDs are upgraded to the embedded ones if those are newer.
+// If the configured images differ from the running ones, they're only rolled out if upgrades are enabled.
+// The tuning is always applied.
+func reconcileArgo(ctx context.Context, clientset kubernetes.Interface, crdClient apixv1client.CustomResourceDefinitionsGetter, namespace, argoImage, redisArgoImage string, upgrade UpgradeOptions, tuning Tuning) (State, error) {
+	log := klog.FromContext(ctx)
+	if upgrade.Enabled {
+		if err := createArgoCRDs(ctx, crdClient, true); err != nil {
+			return StateRunning, fmt.Errorf("could not apply Argo CD CRDs: %w", err)
+		}
 	}
+	runningArgoImage, runningRedisImage, err := runningImages(ctx, clientset, namespace)
+	if err != nil {
+		return StateUnknown, err
+	}
+	runningArgoImage, runningRedisImage = cmp.Or(runningArgoImage, argoImage), cmp.Or(runningRedisImage, redisArgoImage)
 
-	if err := createArgoCRDs(ctx, config); err != nil {
-		return err
+	if runningArgoImage == argoImage && runningRedisImage == redisArgoImage {
+		// Reconcile the components to correct any drift
+		if err := reconcileArgoComponents(ctx, clientset, namespace, argoImage, redisArgoImage, tuning); err != nil {
+			return StateRunning, fmt.Errorf("could not reconcile Argo CD components: %w", err)
+		}
+		return StateRunning, nil
 	}
 
-	if err := createRedisDeployment(ctx, clientset, namespace, argoImage, redisArgoImage); err != nil {
-		return err
+	if !upgrade.Enabled {
+		log.Info("Configured Argo CD images differ from the running images, keeping the running images until upgrades are enabled",
+			"runningImage", runningArgoImage, "configuredImage", argoImage, "runningRedisImage", runningRedisImage, "configuredRedisImage", redisArgoImage)
+		if err := reconcileArgoComponents(ctx, clientset, namespace, runningArgoImage, runningRedisImage, tuning); err != nil {
+			return StateRunning, fmt.Errorf("could not reconcile Argo CD components: %w", err)
+		}
+		return StateRunning, nil
 	}
 
-	if err := createRepoServerDeployment(ctx, clientset, namespace, argoImage); err != nil {
-		return err
+	log.Info("Upgrading Argo CD", "fromImage", runningArgoImage, "toImage", argoImage, "fromRedisImage", runningRedisImage, "toRedisImage", redisArgoImage)
+	err = upgradeArgo(ctx, clientset, namespace, argoImage, redisArgoImage, upgrade.Timeout, tuning)
+	metrics.ArgoCDUpgrades.WithLabelValues(metrics.Result(err)).Inc()
+	if err != nil {
+		events.Warning(events.ReasonArgoCDUpgradeFailed, "Failed to upgrade Argo CD to image %s: %v", argoImage, err)
+		return StateUpgradeFailed, err
 	}
+	events.Normal(events.ReasonArgoCDUpgraded, "Upgraded Argo CD from image %s to %s", runningArgoImage, argoImage)
+	return StateUpgraded, nil
+}
 
-	if err := createServerDeployment(ctx, clientset, namespace, argoImage); err != nil {
+// upgradeArgo rolls out the new images and waits until all components run them
+func upgradeArgo(ctx context.Context, clientset kubernetes.Interface, namespace, argoImage, redisArgoImage string, timeout time.Duration, tuning Tuning) error {
+	if err := reconcileArgoComponents(ctx, clientset, namespace, argoImage, redisArgoImage, tuning); err != nil {
+		return fmt.Errorf("could not roll out the new images: %w", err)
+	}
+	if err := waitForRollout(ctx, clientset, namespace, timeout); err != nil {
 		return err
 	}
+	klog.FromContext(ctx).Info("Upgraded Argo CD", "image", argoImage, "redisImage", redisArgoImage)
+	return nil
+}
 
-	if err := createArgoProject(ctx, cluster, config, namespace, defaultArgoProjectName); err != nil {
-		return err
+// runningImages returns the images of the Argo CD components and of Redis steward still manages.
+// The images are empty if the components don't exist or were all taken over by Argo CD or the operator,
+// steward doesn't roll out images to those and doesn't compare them.
+func runningImages(ctx context.Context, clientset kubernetes.Interface, namespace string) (string, string, error) {
+	deploymentImage := func(name string) (string, error) {
+		d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return managedImage(d, d.Spec.Template.Spec.Containers), nil
+	}
+	statefulSetImage := func(name string) (string, error) {
+		s, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return managedImage(s, s.Spec.Template.Spec.Containers), nil
 	}
 
-	if err := createArgoApp(ctx, cluster, config, namespace, defaultArgoProjectName, defaultArgoRootAppName, argoAppsPathPrefix); err != nil {
-		return err
+	argoImage := ""
+	for _, get := range []func() (string, error){
+		func() (string, error) { return deploymentImage(argoServerName) },
+		func() (string, error) { return deploymentImage(argoRepoServerName) },
+		func() (string, error) { return statefulSetImage(argoApplicationControllerName) },
+	} {
+		image, err := get()
+		if err != nil {
+			return "", "", err
+		}
+		if image != "" {
+			argoImage = image
+			break
+		}
 	}
+	redisImage, err := deploymentImage(argoRedisName)
+	return argoImage, redisImage, err
+}
 
-	if err := createApplicationControllerStatefulSet(ctx, clientset, namespace, argoImage); err != nil {
-		return err
+// managedImage returns the image of the first container, or an empty string if the component was taken over
+func managedImage(obj metav1.Object, containers []corev1.Container) string {
+	if takenOverBy(obj) != "" || len(containers) == 0 {
+		return ""
 	}
+	return containers[0].Image
+}
 
-	return nil
+// reconcileArgoComponents applies argocd-cm and the Argo CD deployments and statefulset with the tuning,
+// components taken over by Argo CD or the operator are skipped
+func reconcileArgoComponents(ctx context.Context, clientset kubernetes.Interface, namespace, argoImage, redisArgoImage string, tuning Tuning) error {
+	if err := applyArgoConfigMap(ctx, clientset, namespace, tuning.Config); err != nil {
+		return err
+	}
+	if err := applyRedisDeployment(ctx, clientset, namespace, argoImage, redisArgoImage, tuning.Redis); err != nil {
+		return err
+	}
+	if err := applyRepoServerDeployment(ctx, clientset, namespace, argoImage, tuning.RepoServer); err != nil {
+		return err
+	}
+	if err := applyServerDeployment(ctx, clientset, namespace, argoImage, tuning.Server); err != nil {
+		return err
+	}
+	return applyApplicationControllerStatefulSet(ctx, clientset, namespace, argoImage, tuning.ApplicationController)
 }
 
 func fixArgoOperatorDeadlock(ctx context.Context, clientset *kubernetes.Clientset, config *rest.Config, namespace, operatorNamespace string) error {
@@ -170,7 +386,7 @@ func fixArgoOperatorDeadlock(ctx context.Context, clientset *kubernetes.Clientse
 
 	for _, pod := range pods.Items {
 		if pod.CreationTimestamp.Time.After(time.Now().Add(-10 * time.Minute)) {
-			klog.Info("ArgoCD Operator pod was recently created, waiting to reboot...")
+			klog.FromContext(ctx).Info("Argo CD operator pod was recently created, waiting to restart it", "operatorNamespace", operatorNamespace, "name", pod.Name)
 			return nil
 		}
 	}
@@ -183,7 +399,7 @@ func fixArgoOperatorDeadlock(ctx context.Context, clientset *kubernetes.Clientse
 
 	if err == nil {
 		if len(secret.ObjectMeta.OwnerReferences) == 0 {
-			klog.Info("Deleting steward-managed ArgoCD secret")
+			klog.FromContext(ctx).Info("Deleting steward-managed Argo CD secret", "kind", "Secret", "name", argoSecretName)
 			err := clientset.CoreV1().Secrets(namespace).Delete(ctx, argoSecretName, metav1.DeleteOptions{})
 			if err != nil {
 				return fmt.Errorf("Could not delete steward-managed ArgoCD secret: %w", err)
@@ -191,10 +407,12 @@ func fixArgoOperatorDeadlock(ctx context.Context, clientset *kubernetes.Clientse
 		}
 	}
 
-	klog.Info("Rebooting ArgoCD operator to resolve deadlock...")
+	klog.FromContext(ctx).Info("Restarting Argo CD operator to resolve deadlock", "operatorNamespace", operatorNamespace)
+	metrics.ArgoCDOperatorRestarts.Inc()
+	events.Normal(events.ReasonArgoCDOperatorRestart, "Restarting the Argo CD operator in namespace %s to resolve its deadlock", operatorNamespace)
 	errors := []error{}
 	for _, pod := range pods.Items {
-		klog.Infof("Removing pod %s", pod.Name)
+		klog.FromContext(ctx).Info("Deleting Argo CD operator pod", "operatorNamespace", operatorNamespace, "kind", "Pod", "name", pod.Name)
 		err := clientset.CoreV1().Pods(operatorNamespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
 		errors = append(errors, err)
 	}
@@ -202,22 +420,44 @@ func fixArgoOperatorDeadlock(ctx context.Context, clientset *kubernetes.Clientse
 	return multierr.Combine(errors...)
 }
 
-func applyAdditionalRootApps(ctx context.Context, clientset *kubernetes.Clientset, config *rest.Config, namespace, additionalRootAppsConfigMapName string, cluster *api.Cluster) error {
-	teamNames, err := readAdditionalRootAppsConfigMap(ctx, clientset, namespace, additionalRootAppsConfigMapName)
+// applyDefaultRootApp reconciles the project and the root app created during the bootstrap,
+// so changes of the catalog repository reach them.
+func applyDefaultRootApp(ctx context.Context, dynamicClient dynamic.Interface, namespace string, cluster *api.Cluster) error {
+	if err := applyArgoProject(ctx, dynamicClient, namespace, defaultArgoProjectName, nil, defaultRootApp.projectSpec(cluster)); err != nil {
+		return err
+	}
+	return applyArgoApp(ctx, dynamicClient, namespace, defaultArgoRootAppName, nil, defaultRootApp.appSpec(cluster, namespace))
+}
+
+// applyAdditionalRootApps applies the root apps and projects of the listed teams and prunes the ones of teams which are no longer listed.
+// Nothing is pruned if the list can't be read, and only confirmed removals if the ConfigMap doesn't exist.
+// Invalid entries are reported and skipped, the objects of their teams are kept.
+func applyAdditionalRootApps(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, opts RootAppOptions, cluster *api.Cluster) error {
+	teams, found, err := readAdditionalRootAppsConfigMap(ctx, clientset, namespace, opts.ConfigMapName)
 	if err != nil {
 		return err
 	}
+	prune := opts.Prune
+	if prune && !found {
+		klog.FromContext(ctx).Info("Additional root apps config map not present, only removing root apps whose removal is confirmed", "name", opts.ConfigMapName)
+		prune = false
+	}
 
-	for _, name := range teamNames {
-		if err := createArgoProject(ctx, cluster, config, namespace, name); err != nil {
+	apps := mergeRootApps(teams, opts.Apps)
+	for _, team := range apps {
+		if err := team.Validate(); err != nil {
+			klog.FromContext(ctx).Error(err, "Skipping invalid additional root app", "team", team.Name)
+			events.Warning(events.ReasonAdditionalRootAppInvalid, "Skipping invalid additional root app: %v", err)
+			continue
+		}
+		labels := map[string]string{additionalRootAppLabel: team.Name}
+		if err := applyArgoProject(ctx, dynamicClient, namespace, team.Name, labels, team.projectSpec(cluster)); err != nil {
 			return err
 		}
-
-		// apps path for additional root apps is `manifests/apps-<team name>/`.
-		if err := createArgoApp(ctx, cluster, config, namespace, name, "root-"+name, argoAppsPathPrefix+"-"+name); err != nil {
+		if err := applyArgoApp(ctx, dynamicClient, namespace, team.AppName(), labels, team.appSpec(cluster, namespace)); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return pruneAdditionalRootApps(ctx, dynamicClient, namespace, apps, prune)
 }
@@ -0,0 +1,92 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+func TestReconcileArgoKeepsRunningImages(t *testing.T) {
+	client := fake.NewClientset()
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v1", "redis:v1", Tuning{}))
+
+	crdClient := newCRDClient()
+	state, err := reconcileArgo(t.Context(), client, crdClient.ApiextensionsV1(), "syn", "argocd:v2", "redis:v2", UpgradeOptions{}, Tuning{})
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, state)
+	crds, err := crdClient.ApiextensionsV1().CustomResourceDefinitions().List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, crds.Items, "CRDs are only upgraded if upgrades are enabled")
+	argoImage, redisImage, err := runningImages(t.Context(), client, "syn")
+	require.NoError(t, err)
+	assert.Equal(t, "argocd:v1", argoImage)
+	assert.Equal(t, "redis:v1", redisImage)
+}
+
+func TestReconcileArgoCorrectsDrift(t *testing.T) {
+	client := fake.NewClientset()
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v1", "redis:v1", Tuning{}))
+	require.NoError(t, applyRepoServerDeployment(t.Context(), client, "syn", "argocd:edited", nil))
+
+	crdClient := newCRDClient()
+	state, err := reconcileArgo(t.Context(), client, crdClient.ApiextensionsV1(), "syn", "argocd:v1", "redis:v1", UpgradeOptions{Enabled: true}, Tuning{})
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, state)
+	for _, name := range argoCRDNames {
+		assert.NotEmpty(t, crdVersion(t, crdClient, name))
+	}
+	assert.Equal(t, "argocd:v1", getDeployment(t, client, argoRepoServerName).Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestRunningImagesMissing(t *testing.T) {
+	argoImage, redisImage, err := runningImages(t.Context(), fake.NewClientset(), "syn")
+	require.NoError(t, err)
+	assert.Empty(t, argoImage)
+	assert.Empty(t, redisImage)
+}
+
+func TestReconcileArgoIgnoresTakenOverImages(t *testing.T) {
+	client := fake.NewClientset()
+	// The catalog synced Argo CD with another image and took over all components
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v3", "redis:v3", Tuning{}))
+	tracked := map[string]string{argoTrackingAnnotation: "argocd"}
+	for _, name := range []string{argoRedisName, argoRepoServerName, argoServerName} {
+		d := getDeployment(t, client, name)
+		d.Annotations = tracked
+		_, err := client.AppsV1().Deployments("syn").Update(t.Context(), d, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	s, err := client.AppsV1().StatefulSets("syn").Get(t.Context(), argoApplicationControllerName, metav1.GetOptions{})
+	require.NoError(t, err)
+	s.Annotations = tracked
+	_, err = client.AppsV1().StatefulSets("syn").Update(t.Context(), s, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	upgrades := testutil.ToFloat64(metrics.ArgoCDUpgrades.WithLabelValues("success"))
+	state, err := reconcileArgo(t.Context(), client, newCRDClient().ApiextensionsV1(), "syn", "argocd:v2", "redis:v2", UpgradeOptions{Enabled: true}, Tuning{})
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, state)
+	assert.Equal(t, upgrades, testutil.ToFloat64(metrics.ArgoCDUpgrades.WithLabelValues("success")))
+	assert.Equal(t, "argocd:v3", getDeployment(t, client, argoServerName).Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestRunningImagesPartlyTakenOver(t *testing.T) {
+	client := fake.NewClientset()
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v1", "redis:v1", Tuning{}))
+	d := getDeployment(t, client, argoServerName)
+	d.Annotations = map[string]string{argoTrackingAnnotation: "argocd"}
+	d.Spec.Template.Spec.Containers[0].Image = "argocd:v3"
+	_, err := client.AppsV1().Deployments("syn").Update(t.Context(), d, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	argoImage, redisImage, err := runningImages(t.Context(), client, "syn")
+	require.NoError(t, err)
+	assert.Equal(t, "argocd:v1", argoImage)
+	assert.Equal(t, "redis:v1", redisImage)
+}
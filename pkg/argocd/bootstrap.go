@@ -0,0 +1,114 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apixv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+)
+
+const (
+	// crdEstablishedTimeout limits the wait for the Argo CD CRDs to be served
+	crdEstablishedTimeout = 30 * time.Second
+	// componentAvailableTimeout limits the wait for an Argo CD component to become available
+	componentAvailableTimeout = 2 * time.Minute
+
+	// MaxBootstrapWait is the longest time a bootstrap waits for the readiness of the Argo CD components in total
+	MaxBootstrapWait = crdEstablishedTimeout + 2*componentAvailableTimeout
+)
+
+// BootstrapError reports the bootstrap phase which failed and the phases completed before
+type BootstrapError struct {
+	Phase     string
+	Completed []string
+	Err       error
+}
+
+func (e *BootstrapError) Error() string {
+	completed := "none"
+	if len(e.Completed) > 0 {
+		completed = strings.Join(e.Completed, ", ")
+	}
+	return fmt.Sprintf("bootstrap phase %s failed (completed phases: %s): %v", e.Phase, completed, e.Err)
+}
+
+func (e *BootstrapError) Unwrap() error {
+	return e.Err
+}
+
+// bootstrapPhase creates some Argo CD objects and optionally waits until they're ready
+type bootstrapPhase struct {
+	name string
+	run  func(context.Context) error
+	// ready waits for the objects created by run within timeout
+	ready   func(ctx context.Context, timeout time.Duration) error
+	timeout time.Duration
+}
+
+// runBootstrapPhases runs the phases in order, a phase only starts once the previous one is ready
+func runBootstrapPhases(ctx context.Context, phases []bootstrapPhase) error {
+	completed := []string{}
+	for _, phase := range phases {
+		log := klog.FromContext(ctx).WithValues("phase", phase.name)
+		ctx := klog.NewContext(ctx, log)
+		log.V(1).Info("Bootstrapping Argo CD")
+		err := phase.run(ctx)
+		if err == nil && phase.ready != nil {
+			err = phase.ready(ctx, phase.timeout)
+		}
+		if err != nil {
+			log.Error(err, "Bootstrapping Argo CD failed", "completedPhases", completed)
+			return &BootstrapError{Phase: phase.name, Completed: completed, Err: err}
+		}
+		completed = append(completed, phase.name)
+	}
+	return nil
+}
+
+// waitForCRDsEstablished waits until the Argo CD CRDs are served by the API server
+func waitForCRDsEstablished(ctx context.Context, client apixv1client.CustomResourceDefinitionsGetter, timeout time.Duration) error {
+	for _, name := range argoCRDNames {
+		if err := waitFor(ctx, timeout, "CustomResourceDefinition", name, func(ctx context.Context) (bool, error) {
+			crd, err := client.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return CRDEstablished(crd), nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForDeploymentAvailable waits until all replicas of the deployment are available
+func waitForDeploymentAvailable(ctx context.Context, clientset kubernetes.Interface, namespace, name string, timeout time.Duration) error {
+	return waitFor(ctx, timeout, "Deployment", name, func(ctx context.Context) (bool, error) {
+		d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return deploymentRolledOut(d), nil
+	})
+}
+
+// CRDEstablished returns true if the CRD's names are accepted and it's served
+func CRDEstablished(crd *apixv1.CustomResourceDefinition) bool {
+	established, namesAccepted := false, false
+	for _, c := range crd.Status.Conditions {
+		switch c.Type {
+		case apixv1.Established:
+			established = c.Status == apixv1.ConditionTrue
+		case apixv1.NamesAccepted:
+			namesAccepted = c.Status == apixv1.ConditionTrue
+		}
+	}
+	return established && namesAccepted
+}
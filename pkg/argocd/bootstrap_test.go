@@ -0,0 +1,128 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunBootstrapPhases(t *testing.T) {
+	ran := []string{}
+	phase := func(name string, err error) bootstrapPhase {
+		return bootstrapPhase{name: name, run: func(context.Context) error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+	notReady := errors.New("not ready")
+
+	err := runBootstrapPhases(t.Context(), []bootstrapPhase{
+		phase("first", nil),
+		{
+			name: "second",
+			run:  func(context.Context) error { ran = append(ran, "second"); return nil },
+			ready: func(_ context.Context, timeout time.Duration) error {
+				assert.Equal(t, time.Second, timeout)
+				return notReady
+			},
+			timeout: time.Second,
+		},
+		phase("third", nil),
+	})
+
+	var bootstrapErr *BootstrapError
+	require.ErrorAs(t, err, &bootstrapErr)
+	assert.Equal(t, "second", bootstrapErr.Phase)
+	assert.Equal(t, []string{"first"}, bootstrapErr.Completed)
+	assert.ErrorIs(t, err, notReady)
+	assert.Equal(t, "bootstrap phase second failed (completed phases: first): not ready", err.Error())
+	assert.Equal(t, []string{"first", "second"}, ran)
+}
+
+func TestRunBootstrapPhasesSkipsReadyOnError(t *testing.T) {
+	failed := errors.New("create failed")
+	err := runBootstrapPhases(t.Context(), []bootstrapPhase{{
+		name: "first",
+		run:  func(context.Context) error { return failed },
+		ready: func(context.Context, time.Duration) error {
+			t.Fatal("ready must not be called after a failed run")
+			return nil
+		},
+	}})
+	assert.ErrorIs(t, err, failed)
+	assert.EqualError(t, err, "bootstrap phase first failed (completed phases: none): create failed")
+}
+
+func TestCRDEstablished(t *testing.T) {
+	condition := func(t apixv1.CustomResourceDefinitionConditionType, s apixv1.ConditionStatus) apixv1.CustomResourceDefinitionCondition {
+		return apixv1.CustomResourceDefinitionCondition{Type: t, Status: s}
+	}
+	tcs := map[string]struct {
+		conditions []apixv1.CustomResourceDefinitionCondition
+		expected   bool
+	}{
+		"established": {
+			conditions: []apixv1.CustomResourceDefinitionCondition{
+				condition(apixv1.NamesAccepted, apixv1.ConditionTrue),
+				condition(apixv1.Established, apixv1.ConditionTrue),
+			},
+			expected: true,
+		},
+		"no conditions": {},
+		"names not accepted": {
+			conditions: []apixv1.CustomResourceDefinitionCondition{
+				condition(apixv1.NamesAccepted, apixv1.ConditionFalse),
+				condition(apixv1.Established, apixv1.ConditionTrue),
+			},
+		},
+		"not established": {
+			conditions: []apixv1.CustomResourceDefinitionCondition{
+				condition(apixv1.NamesAccepted, apixv1.ConditionTrue),
+				condition(apixv1.Established, apixv1.ConditionFalse),
+			},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			crd := &apixv1.CustomResourceDefinition{Status: apixv1.CustomResourceDefinitionStatus{Conditions: tc.conditions}}
+			assert.Equal(t, tc.expected, CRDEstablished(crd))
+		})
+	}
+}
+
+func TestWaitForCRDsEstablished(t *testing.T) {
+	established := []apixv1.CustomResourceDefinitionCondition{
+		{Type: apixv1.NamesAccepted, Status: apixv1.ConditionTrue},
+		{Type: apixv1.Established, Status: apixv1.ConditionTrue},
+	}
+	application := makeCRD(argoCRDNames[0], nil)
+	application.Status.Conditions = established
+	project := makeCRD(argoCRDNames[1], nil)
+
+	client := newCRDClient(application, project)
+	err := waitForCRDsEstablished(t.Context(), client.ApiextensionsV1(), 10*time.Millisecond)
+	assert.ErrorContains(t, err, "CustomResourceDefinition "+argoCRDNames[1]+" not ready")
+
+	project.Status.Conditions = established
+	_, err = client.ApiextensionsV1().CustomResourceDefinitions().UpdateStatus(t.Context(), project, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, waitForCRDsEstablished(t.Context(), client.ApiextensionsV1(), time.Second))
+}
+
+func TestWaitForDeploymentAvailable(t *testing.T) {
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: argoRedisName, Namespace: "syn"},
+	})
+	err := waitForDeploymentAvailable(t.Context(), client, "syn", argoRedisName, 10*time.Millisecond)
+	assert.ErrorContains(t, err, "Deployment "+argoRedisName+" not ready")
+
+	require.NoError(t, waitForDeploymentAvailable(t.Context(), rolledOutComponents(), "syn", argoRepoServerName, time.Second))
+}
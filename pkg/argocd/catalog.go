@@ -0,0 +1,100 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+// reconcileCatalogRepo reconciles the objects of a bootstrapped Argo CD which reference the catalog repository.
+// If Lieutenant reports a new URL, the known hosts, the root app and project, the SSH credentials and the repository secret
+// are migrated to it in this order. The additional root apps already follow the URL reported by Lieutenant.
+// The repository secret is updated last, so a migration which failed part way is detected and retried on the next sync.
+// Nothing is migrated if the repository secret was taken over, the catalog manages the repository then.
+func reconcileCatalogRepo(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, cluster *api.Cluster) error {
+	newURL, err := catalogURL(cluster)
+	if err != nil {
+		return err
+	}
+	currentURL, manager, err := repoSecretURL(ctx, clientset, namespace)
+	if err != nil {
+		return err
+	}
+	if manager != "" {
+		klog.FromContext(ctx).V(1).Info("Argo CD repository secret is managed by someone else, not migrating the catalog repository",
+			"kind", "Secret", "name", argoRepoSecretName, "manager", manager)
+		return applyDefaultRootApp(ctx, dynamicClient, namespace, cluster)
+	}
+	if currentURL == newURL {
+		return applyDefaultRootApp(ctx, dynamicClient, namespace, cluster)
+	}
+	if currentURL == "" {
+		klog.FromContext(ctx).Info("Argo CD repository secret missing, recreating it", "kind", "Secret", "name", argoRepoSecretName)
+		return migrateCatalogRepo(ctx, clientset, dynamicClient, namespace, cluster)
+	}
+
+	log := klog.FromContext(ctx).WithValues("fromURL", currentURL, "toURL", newURL)
+	log.Info("Catalog repository URL changed, migrating")
+	err = migrateCatalogRepo(ctx, clientset, dynamicClient, namespace, cluster)
+	metrics.CatalogMigrations.WithLabelValues(metrics.Result(err)).Inc()
+	if err != nil {
+		events.Warning(events.ReasonCatalogMigrationFailed, "Failed to migrate the catalog repository from %s to %s: %v", currentURL, newURL, err)
+		return fmt.Errorf("could not migrate the catalog repository: %w", err)
+	}
+	log.Info("Migrated catalog repository")
+	events.Normal(events.ReasonCatalogMigrated, "Migrated the catalog repository from %s to %s", currentURL, newURL)
+	return nil
+}
+
+func migrateCatalogRepo(ctx context.Context, clientset kubernetes.Interface, dynamicClient dynamic.Interface, namespace string, cluster *api.Cluster) error {
+	if hostKeys := cluster.GitRepo.HostKeys; hostKeys != nil {
+		if err := applyKnownHosts(ctx, clientset, namespace, *hostKeys); err != nil {
+			return fmt.Errorf("could not update the known hosts: %w", err)
+		}
+	}
+	if err := applyDefaultRootApp(ctx, dynamicClient, namespace, cluster); err != nil {
+		return fmt.Errorf("could not update the root app: %w", err)
+	}
+	if err := createRepoSecret(ctx, cluster, clientset, namespace); err != nil {
+		return fmt.Errorf("could not update the repository secrets: %w", err)
+	}
+	return nil
+}
+
+// catalogURL returns the normalized URL of the catalog repository reported by Lieutenant
+func catalogURL(cluster *api.Cluster) (string, error) {
+	if cluster == nil {
+		return "", fmt.Errorf("no cluster passed")
+	}
+	if cluster.GitRepo == nil || cluster.GitRepo.Url == nil {
+		return "", fmt.Errorf("no git repo information received from API for cluster '%s'", cluster.Id)
+	}
+	gitURL, err := url.Parse(*cluster.GitRepo.Url)
+	if err != nil {
+		return "", err
+	}
+	return gitURL.String(), nil
+}
+
+// repoSecretURL returns the URL of the Argo CD repository secret, or an empty string if it doesn't exist,
+// and who took over the secret from steward
+func repoSecretURL(ctx context.Context, clientset kubernetes.Interface, namespace string) (string, string, error) {
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, argoRepoSecretName, metav1.GetOptions{})
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	return string(secret.Data["url"]), takenOverBy(secret), nil
+}
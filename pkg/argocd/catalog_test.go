@@ -0,0 +1,159 @@
+package argocd
+
+import (
+	"cmp"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+
+	"github.com/projectsyn/steward/pkg/events"
+	"github.com/projectsyn/steward/pkg/metrics"
+)
+
+const (
+	oldCatalogURL = "ssh://git@git.example.com/catalog.git"
+	newCatalogURL = "ssh://git@git.example.org/catalog.git"
+)
+
+func makeRepoSecret(url string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: argoRepoSecretName, Namespace: "syn"},
+		Data:       map[string][]byte{"type": []byte("git"), "url": []byte(url)},
+	}
+}
+
+func TestReconcileCatalogRepo(t *testing.T) {
+	tcs := map[string]struct {
+		repoSecret    *corev1.Secret
+		sshSecret     *corev1.Secret
+		failApply     bool
+		repoSecretURL string
+		sshURL        string
+		migrations    float64
+		failures      float64
+		event         string
+		errorMessage  string
+	}{
+		"unchanged": {
+			repoSecret:    makeRepoSecret(newCatalogURL),
+			repoSecretURL: newCatalogURL,
+		},
+		"changed": {
+			repoSecret:    makeRepoSecret(oldCatalogURL),
+			repoSecretURL: newCatalogURL,
+			sshURL:        newCatalogURL,
+			migrations:    1,
+			event:         events.ReasonCatalogMigrated,
+		},
+		"taken over": {
+			repoSecret: func() *corev1.Secret {
+				s := makeRepoSecret(oldCatalogURL)
+				s.Annotations = map[string]string{argoTrackingAnnotation: "argocd:/Secret:syn/cluster-catalog"}
+				return s
+			}(),
+			repoSecretURL: oldCatalogURL,
+		},
+		"taken over ssh secret": {
+			repoSecret: makeRepoSecret(oldCatalogURL),
+			sshSecret: func() *corev1.Secret {
+				s := makeSSHSecret("thepubkey")
+				s.Annotations = map[string]string{argoTrackingAnnotation: "argocd:/Secret:syn/argo-ssh-key"}
+				return s
+			}(),
+			repoSecretURL: newCatalogURL,
+			migrations:    1,
+			event:         events.ReasonCatalogMigrated,
+		},
+		"missing repo secret": {
+			repoSecretURL: newCatalogURL,
+			sshURL:        newCatalogURL,
+		},
+		"failed": {
+			repoSecret:    makeRepoSecret(oldCatalogURL),
+			failApply:     true,
+			repoSecretURL: oldCatalogURL,
+			failures:      1,
+			event:         events.ReasonCatalogMigrationFailed,
+			errorMessage:  "could not migrate the catalog repository: could not update the root app: apply failed",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			rec := record.NewFakeRecorder(10)
+			events.SetRecorder(rec, nil)
+			t.Cleanup(func() { events.SetRecorder(nil, nil) })
+			migrations := testutil.ToFloat64(metrics.CatalogMigrations.WithLabelValues("success"))
+			failures := testutil.ToFloat64(metrics.CatalogMigrations.WithLabelValues("failure"))
+
+			objects := []runtime.Object{cmp.Or(tc.sshSecret, makeSSHSecret("thepubkey"))}
+			if tc.repoSecret != nil {
+				objects = append(objects, tc.repoSecret)
+			}
+			clientset := fake.NewClientset(objects...)
+			dynamicClient := newArgoClient(argoTestObject("Application", defaultArgoRootAppName, defaultArgoProjectName, nil, nil))
+			if tc.failApply {
+				dynamicClient.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("apply failed")
+				})
+			}
+			cluster := makeCluster(t, "c-test-1234", newCatalogURL)
+			cluster.GitRepo.HostKeys = ptr.To("git.example.org ssh-ed25519 AAAA")
+
+			err := reconcileCatalogRepo(t.Context(), clientset, dynamicClient, "syn", cluster)
+			if tc.errorMessage != "" {
+				require.EqualError(t, err, tc.errorMessage)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, migrations+tc.migrations, testutil.ToFloat64(metrics.CatalogMigrations.WithLabelValues("success")))
+			assert.Equal(t, failures+tc.failures, testutil.ToFloat64(metrics.CatalogMigrations.WithLabelValues("failure")))
+			if tc.event != "" {
+				require.Len(t, rec.Events, 1)
+				assert.Contains(t, <-rec.Events, tc.event)
+			} else {
+				assert.Empty(t, rec.Events)
+			}
+
+			repoSecret, err := clientset.CoreV1().Secrets("syn").Get(t.Context(), argoRepoSecretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.repoSecretURL, string(repoSecret.Data["url"]))
+			if tc.failApply {
+				return
+			}
+			sshSecret, err := clientset.CoreV1().Secrets("syn").Get(t.Context(), argoSSHSecretName, metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.sshURL, string(sshSecret.Data["url"]))
+			app, err := dynamicClient.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), defaultArgoRootAppName, metav1.GetOptions{})
+			require.NoError(t, err)
+			repoURL, _, _ := unstructured.NestedString(app.Object, "spec", "source", "repoURL")
+			assert.Equal(t, newCatalogURL, repoURL)
+		})
+	}
+}
+
+func TestReconcileCatalogRepoKnownHosts(t *testing.T) {
+	clientset := fake.NewClientset(makeSSHSecret("thepubkey"), makeRepoSecret(oldCatalogURL), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: argoSSHConfigMapName, Namespace: "syn"},
+		Data:       map[string]string{"ssh_known_hosts": "git.example.com ssh-ed25519 AAAA"},
+	})
+	cluster := makeCluster(t, "c-test-1234", newCatalogURL)
+	cluster.GitRepo.HostKeys = ptr.To("git.example.org ssh-ed25519 BBBB")
+
+	require.NoError(t, reconcileCatalogRepo(t.Context(), clientset, newArgoClient(), "syn", cluster))
+
+	cm, err := clientset.CoreV1().ConfigMaps("syn").Get(t.Context(), argoSSHConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "git.example.org ssh-ed25519 BBBB", cm.Data["ssh_known_hosts"])
+}
@@ -2,12 +2,14 @@ package argocd
 
 import (
 	"context"
+	"maps"
 
 	"github.com/projectsyn/lieutenant-api/pkg/api"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -20,6 +22,13 @@ var (
 `
 )
 
+// argoConfigDefaults are the settings of argocd-cm set by steward, the Argo CD tuning can't change them
+var argoConfigDefaults = map[string]string{
+	"configManagementPlugins":            pluginString,
+	"application.instanceLabelKey":       argoInstanceLabel,
+	"application.resourceTrackingMethod": "label",
+}
+
 func createArgoCDConfigMaps(ctx context.Context, cluster *api.Cluster, clientset *kubernetes.Clientset, namespace string) error {
 	cmLabel := map[string]string{
 		"app.kubernetes.io/part-of": "argocd",
@@ -51,17 +60,6 @@ func createArgoCDConfigMaps(ctx context.Context, cluster *api.Cluster, clientset
 			Labels: cmLabel,
 		},
 	}
-	argoConfigMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   argoConfigMapName,
-			Labels: cmLabel,
-		},
-		Data: map[string]string{
-			"configManagementPlugins":            pluginString,
-			"application.instanceLabelKey":       "argocd.argoproj.io/instance",
-			"application.resourceTrackingMethod": "label",
-		},
-	}
 
 	if err := createOrUpdateConfigMap(ctx, clientset, namespace, tlsConfigMap); err != nil {
 		return nil
@@ -69,21 +67,45 @@ func createArgoCDConfigMaps(ctx context.Context, cluster *api.Cluster, clientset
 	if err := createOrUpdateConfigMap(ctx, clientset, namespace, rbacConfigMap); err != nil {
 		return nil
 	}
-	if err := createOrUpdateConfigMap(ctx, clientset, namespace, argoConfigMap); err != nil {
-		return nil
-	}
 	return nil
 }
 
+// applyKnownHosts reconciles the SSH host keys of the catalog repository
+func applyKnownHosts(ctx context.Context, clientset kubernetes.Interface, namespace, hostKeys string) error {
+	return applyObject(ctx, clientset.CoreV1().ConfigMaps(namespace), corev1ac.ConfigMap(argoSSHConfigMapName, namespace).
+		WithLabels(map[string]string{
+			"app.kubernetes.io/part-of": "argocd",
+		}).
+		WithData(map[string]string{
+			"ssh_known_hosts": hostKeys,
+		}),
+	)
+}
+
+// applyArgoConfigMap reconciles argocd-cm with the settings of steward and the additional settings of the tuning
+func applyArgoConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace string, config map[string]string) error {
+	data := maps.Clone(config)
+	if data == nil {
+		data = map[string]string{}
+	}
+	maps.Copy(data, argoConfigDefaults)
+	return applyObject(ctx, clientset.CoreV1().ConfigMaps(namespace), corev1ac.ConfigMap(argoConfigMapName, namespace).
+		WithLabels(map[string]string{
+			"app.kubernetes.io/part-of": "argocd",
+		}).
+		WithData(data),
+	)
+}
+
 func createOrUpdateConfigMap(ctx context.Context, clientset *kubernetes.Clientset, namespace string, configMap *corev1.ConfigMap) error {
 	_, err := clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, createOpts)
 	if err != nil {
 		if errors.IsAlreadyExists(err) {
-			klog.Infof("Update existing ConfigMap %s", configMap.Name)
+			klog.FromContext(ctx).Info("Updating existing Argo CD object", "kind", "ConfigMap", "name", configMap.Name)
 			_, err = clientset.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, updateOpts)
 		}
 		return err
 	}
-	klog.Infof("Created new ConfigMap")
+	klog.FromContext(ctx).Info("Created Argo CD object", "kind", "ConfigMap", "name", configMap.Name)
 	return nil
 }
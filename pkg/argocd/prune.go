@@ -0,0 +1,170 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	k8err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/projectsyn/steward/pkg/events"
+)
+
+const (
+	// additionalRootAppLabel marks the root apps and projects steward created for a team, the value is the team name
+	additionalRootAppLabel = "steward.syn.tools/additional-root-app"
+	// confirmRemovalAnnotation confirms the deletion of a root app or project whose team is no longer listed
+	confirmRemovalAnnotation = "steward.syn.tools/confirm-removal"
+	// removalPendingAnnotation records since when the removal of a root app or project waits for its confirmation,
+	// so the pending removal is only reported once
+	removalPendingAnnotation = "steward.syn.tools/removal-pending-since"
+	// argoResourcesFinalizer makes Argo CD delete the resources of an app before the app itself.
+	// The background and foreground variants are suffixed with the propagation policy.
+	argoResourcesFinalizer = "resources-finalizer.argocd.argoproj.io"
+)
+
+// pruneAdditionalRootApps removes the root apps and projects of teams which are no longer listed.
+// Without prune an object is only removed if it has the confirm removal annotation, otherwise the pending removal is reported.
+// The apps are removed before the projects, a project is kept as long as any app uses it.
+func pruneAdditionalRootApps(ctx context.Context, client dynamic.Interface, namespace string, apps []RootApp, prune bool) error {
+	listed := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		listed[app.Name] = true
+	}
+
+	var errs error
+	for _, kind := range []struct {
+		name   string
+		client dynamic.ResourceInterface
+	}{
+		{"Application", client.Resource(argoAppGVR).Namespace(namespace)},
+		{"AppProject", client.Resource(argoProjectGVR).Namespace(namespace)},
+	} {
+		objs, err := kind.client.List(ctx, metav1.ListOptions{LabelSelector: additionalRootAppLabel})
+		if err != nil {
+			if k8err.IsNotFound(err) {
+				// Argo CD isn't bootstrapped yet
+				return nil
+			}
+			return fmt.Errorf("unable to list the additional root %s objects: %w", kind.name, err)
+		}
+		for i := range objs.Items {
+			obj := &objs.Items[i]
+			obj.SetKind(kind.name)
+			team := obj.GetLabels()[additionalRootAppLabel]
+			if listed[team] {
+				// The team was listed again
+				errs = multierr.Append(errs, setRemovalPending(ctx, kind.client, obj, ""))
+				continue
+			}
+			errs = multierr.Append(errs, removeArgoObject(ctx, client, kind.client, obj, team, prune))
+		}
+	}
+	return errs
+}
+
+// removeArgoObject deletes the object of a team which is no longer listed once the removal is confirmed.
+// The Argo CD resources finalizer is removed first, so the resources of an app aren't deleted with it.
+func removeArgoObject(ctx context.Context, client dynamic.Interface, resource dynamic.ResourceInterface, obj *unstructured.Unstructured, team string, prune bool) error {
+	kind, name := obj.GetKind(), obj.GetName()
+	log := klog.FromContext(ctx).WithValues("kind", kind, "name", name, "team", team)
+
+	if !prune && obj.GetAnnotations()[confirmRemovalAnnotation] != "true" {
+		if _, ok := obj.GetAnnotations()[removalPendingAnnotation]; ok {
+			log.V(1).Info("Team is no longer listed, keeping Argo CD object until its removal is confirmed", "annotation", confirmRemovalAnnotation)
+			return nil
+		}
+		if err := setRemovalPending(ctx, resource, obj, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+		log.Info("Team is no longer listed, keeping Argo CD object until its removal is confirmed", "annotation", confirmRemovalAnnotation)
+		events.Normal(events.ReasonAdditionalRootAppRemovalPending, "%s %s of team %s is no longer listed, annotate it with %s=true or enable pruning to delete it", kind, name, team, confirmRemovalAnnotation)
+		return nil
+	}
+
+	if kind == "AppProject" {
+		users, err := projectUsers(ctx, client, obj.GetNamespace(), name)
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			log.Info("Argo CD project is still in use, keeping it", "apps", users)
+			return nil
+		}
+	}
+
+	finalizers := make([]string, 0, len(obj.GetFinalizers()))
+	for _, f := range obj.GetFinalizers() {
+		if !strings.HasPrefix(f, argoResourcesFinalizer) {
+			finalizers = append(finalizers, f)
+		}
+	}
+	if len(finalizers) != len(obj.GetFinalizers()) {
+		obj.SetFinalizers(finalizers)
+		updated, err := resource.Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to remove the Argo CD finalizer from %s %s: %w", kind, name, err)
+		}
+		obj = updated
+		log.Info("Removed Argo CD resources finalizer")
+	}
+
+	if obj.GetDeletionTimestamp() == nil {
+		if err := resource.Delete(ctx, name, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: ptr.To(obj.GetUID())},
+		}); err != nil && !k8err.IsNotFound(err) {
+			return fmt.Errorf("unable to delete %s %s: %w", kind, name, err)
+		}
+	}
+	log.Info("Deleted Argo CD object")
+	events.Normal(events.ReasonAdditionalRootAppRemoved, "Deleted %s %s of team %s which is no longer listed", kind, name, team)
+	return nil
+}
+
+// setRemovalPending sets the removal pending annotation to the given time, or removes it if since is empty.
+// Nothing is patched if the annotation is already in the desired state.
+func setRemovalPending(ctx context.Context, resource dynamic.ResourceInterface, obj *unstructured.Unstructured, since string) error {
+	if _, pending := obj.GetAnnotations()[removalPendingAnnotation]; pending == (since != "") {
+		return nil
+	}
+	var value interface{} // null removes the annotation
+	if since != "" {
+		value = since
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{removalPendingAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := resource.Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{FieldManager: fieldManager}); err != nil {
+		return fmt.Errorf("unable to update the pending removal of %s %s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return nil
+}
+
+// projectUsers returns the names of the apps in the project
+func projectUsers(ctx context.Context, client dynamic.Interface, namespace, project string) ([]string, error) {
+	apps, err := client.Resource(argoAppGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the apps of project %s: %w", project, err)
+	}
+	users := []string{}
+	for _, app := range apps.Items {
+		if p, _, _ := unstructured.NestedString(app.Object, "spec", "project"); p == project {
+			users = append(users, app.GetName())
+		}
+	}
+	return users, nil
+}
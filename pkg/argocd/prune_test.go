@@ -0,0 +1,200 @@
+package argocd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8err "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/projectsyn/steward/pkg/events"
+)
+
+func TestPruneAdditionalRootApps(t *testing.T) {
+	teamB := map[string]string{additionalRootAppLabel: "team-b"}
+	confirmed := map[string]string{confirmRemovalAnnotation: "true"}
+
+	tcs := map[string]struct {
+		objects           []runtime.Object
+		apps              []RootApp
+		prune             bool
+		remainingApps     []string
+		remainingProjects []string
+	}{
+		"dry run": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root-team-b", "team-b", teamB, nil, argoResourcesFinalizer),
+				argoTestObject("AppProject", "team-b", "", teamB, nil),
+			},
+			remainingApps:     []string{"root-team-b"},
+			remainingProjects: []string{"team-b"},
+		},
+		"listed": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root-team-b", "team-b", teamB, nil),
+				argoTestObject("AppProject", "team-b", "", teamB, nil),
+			},
+			apps:              []RootApp{{Name: "team-b"}},
+			prune:             true,
+			remainingApps:     []string{"root-team-b"},
+			remainingProjects: []string{"team-b"},
+		},
+		"unlabeled": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root", "syn", nil, nil),
+				argoTestObject("AppProject", "syn", "", nil, nil),
+			},
+			prune:             true,
+			remainingApps:     []string{"root"},
+			remainingProjects: []string{"syn"},
+		},
+		"confirmed": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root-team-b", "team-b", teamB, confirmed, argoResourcesFinalizer, "example.com/keep"),
+				argoTestObject("AppProject", "team-b", "", teamB, nil),
+			},
+			remainingApps:     []string{},
+			remainingProjects: []string{"team-b"},
+		},
+		"pruned": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root-team-b", "team-b", teamB, nil, argoResourcesFinalizer+"/background"),
+				argoTestObject("AppProject", "team-b", "", teamB, nil),
+			},
+			prune:             true,
+			remainingApps:     []string{},
+			remainingProjects: []string{},
+		},
+		"project in use": {
+			objects: []runtime.Object{
+				argoTestObject("Application", "root-team-b", "team-b", teamB, nil, argoResourcesFinalizer),
+				argoTestObject("Application", "team-b-app", "team-b", nil, nil, argoResourcesFinalizer),
+				argoTestObject("AppProject", "team-b", "", teamB, nil),
+			},
+			prune:             true,
+			remainingApps:     []string{"team-b-app"},
+			remainingProjects: []string{"team-b"},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := newArgoClient(tc.objects...)
+			require.NoError(t, pruneAdditionalRootApps(t.Context(), client, "syn", tc.apps, tc.prune))
+
+			assert.ElementsMatch(t, tc.remainingApps, argoObjectNames(t, client, argoAppGVR))
+			assert.ElementsMatch(t, tc.remainingProjects, argoObjectNames(t, client, argoProjectGVR))
+		})
+	}
+}
+
+func TestPruneAdditionalRootAppsReportsPendingRemovalOnce(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	events.SetRecorder(rec, nil)
+	t.Cleanup(func() { events.SetRecorder(nil, nil) })
+	teamB := map[string]string{additionalRootAppLabel: "team-b"}
+	client := newArgoClient(argoTestObject("Application", "root-team-b", "team-b", teamB, nil))
+
+	for range 2 {
+		require.NoError(t, pruneAdditionalRootApps(t.Context(), client, "syn", nil, false))
+	}
+	require.Len(t, rec.Events, 1)
+	assert.Contains(t, <-rec.Events, events.ReasonAdditionalRootAppRemovalPending)
+	app, err := client.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), "root-team-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, app.GetAnnotations(), removalPendingAnnotation)
+
+	// Listing the team again resets the pending removal
+	require.NoError(t, pruneAdditionalRootApps(t.Context(), client, "syn", []RootApp{{Name: "team-b"}}, false))
+	app, err = client.Resource(argoAppGVR).Namespace("syn").Get(t.Context(), "root-team-b", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, app.GetAnnotations(), removalPendingAnnotation)
+}
+
+func TestApplyAdditionalRootAppsWithoutConfigMap(t *testing.T) {
+	client := newArgoClient(
+		argoTestObject("Application", "root-team-a", "team-a", map[string]string{additionalRootAppLabel: "team-a"}, nil),
+		argoTestObject("Application", "root-team-b", "team-b", map[string]string{additionalRootAppLabel: "team-b"}, map[string]string{confirmRemovalAnnotation: "true"}),
+	)
+
+	require.NoError(t, applyAdditionalRootApps(t.Context(), fake.NewClientset(), client, "syn", RootAppOptions{
+		ConfigMapName: "additional-root-apps",
+		Prune:         true,
+	}, catalogCluster()))
+
+	assert.ElementsMatch(t, []string{"root-team-a"}, argoObjectNames(t, client, argoAppGVR))
+}
+
+func TestApplyAdditionalRootApps(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "additional-root-apps", Namespace: "syn"},
+		Data:       map[string]string{additionalRootAppsConfigKey: `["team-a"]`},
+	})
+	// Created by an older version of steward, before the root apps were labeled
+	client := newArgoClient(
+		argoTestObject("Application", "root-team-a", "team-a", nil, nil, argoResourcesFinalizer),
+		argoTestObject("Application", "root-team-b", "team-b", map[string]string{additionalRootAppLabel: "team-b"}, nil),
+	)
+
+	require.NoError(t, applyAdditionalRootApps(t.Context(), clientset, client, "syn", RootAppOptions{
+		ConfigMapName: "additional-root-apps",
+		Apps:          []RootApp{{Name: "team-c"}},
+		Prune:         true,
+	}, catalogCluster()))
+
+	assert.ElementsMatch(t, []string{"root-team-a", "root-team-c"}, argoObjectNames(t, client, argoAppGVR))
+	assert.ElementsMatch(t, []string{"team-a", "team-c"}, argoObjectNames(t, client, argoProjectGVR))
+	for _, gvr := range []schema.GroupVersionResource{argoAppGVR, argoProjectGVR} {
+		objs, err := client.Resource(gvr).Namespace("syn").List(t.Context(), metav1.ListOptions{})
+		require.NoError(t, err)
+		for _, obj := range objs.Items {
+			assert.Equal(t, strings.TrimPrefix(obj.GetName(), rootAppNamePrefix), obj.GetLabels()[additionalRootAppLabel], obj.GetName())
+		}
+	}
+}
+
+func TestApplyAdditionalRootAppsSkipsInvalid(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	events.SetRecorder(rec, nil)
+	t.Cleanup(func() { events.SetRecorder(nil, nil) })
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "additional-root-apps", Namespace: "syn"},
+		Data:       map[string]string{additionalRootAppsConfigKey: `["team.a", {"name": "team-b", "path": "/etc"}]`},
+	})
+	client := newArgoClient(argoTestObject("Application", "root-team-b", "team-b", map[string]string{additionalRootAppLabel: "team-b"}, nil))
+
+	require.NoError(t, applyAdditionalRootApps(t.Context(), clientset, client, "syn", RootAppOptions{
+		ConfigMapName: "additional-root-apps",
+		Prune:         true,
+	}, catalogCluster()))
+
+	assert.ElementsMatch(t, []string{"root-team.a", "root-team-b"}, argoObjectNames(t, client, argoAppGVR))
+	require.Len(t, rec.Events, 1)
+	assert.Contains(t, <-rec.Events, events.ReasonAdditionalRootAppInvalid)
+}
+
+func TestPruneAdditionalRootAppsWithoutCRDs(t *testing.T) {
+	client := newArgoClient()
+	client.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8err.NewNotFound(action.GetResource().GroupResource(), "")
+	})
+	assert.NoError(t, pruneAdditionalRootApps(t.Context(), client, "syn", nil, true))
+}
+
+func argoObjectNames(t *testing.T, client *dynamicfake.FakeDynamicClient, gvr schema.GroupVersionResource) []string {
+	objs, err := client.Resource(gvr).Namespace("syn").List(t.Context(), metav1.ListOptions{})
+	require.NoError(t, err)
+	names := []string{}
+	for _, obj := range objs.Items {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
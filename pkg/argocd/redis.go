@@ -5,16 +5,15 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	k8serr "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
 )
 
-func createRedisDeployment(ctx context.Context, clientset *kubernetes.Clientset, namespace, argoImage, redisImage string) error {
-	name := "argocd-redis"
+func applyRedisDeployment(ctx context.Context, clientset kubernetes.Interface, namespace, argoImage, redisImage string, tuning *ComponentTuning) error {
+	name := argoRedisName
 	labels := map[string]string{
 		"app.kubernetes.io/component": "redis",
 		"app.kubernetes.io/name":      name,
@@ -23,87 +22,54 @@ func createRedisDeployment(ctx context.Context, clientset *kubernetes.Clientset,
 		labels[k] = v
 	}
 	annotations := argoAnnotations
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        name,
-			Namespace:   namespace,
-			Labels:      labels,
-			Annotations: annotations,
-		},
-		Spec: corev1.ServiceSpec{
-			Type: corev1.ServiceTypeClusterIP,
-			Selector: map[string]string{
+	service := corev1ac.Service(name, namespace).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithSpec(corev1ac.ServiceSpec().
+			WithType(corev1.ServiceTypeClusterIP).
+			WithSelector(map[string]string{
 				"app.kubernetes.io/name": name,
-			},
-			Ports: []corev1.ServicePort{{
-				Name: "redis",
-				Port: 6379,
-				TargetPort: intstr.IntOrString{
-					Type:   intstr.Int,
-					IntVal: 6379,
-				}},
-			},
-		},
-	}
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        name,
-			Namespace:   namespace,
-			Labels:      labels,
-			Annotations: annotations,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
+			}).
+			WithPorts(corev1ac.ServicePort().
+				WithName("redis").
+				WithPort(6379).
+				WithTargetPort(intstr.FromInt32(6379)),
+			),
+		)
+	deployment := appsv1ac.Deployment(name, namespace).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithSpec(appsv1ac.DeploymentSpec().
+			WithSelector(metav1ac.LabelSelector().
+				WithMatchLabels(map[string]string{
 					"app.kubernetes.io/name": name,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app.kubernetes.io/name": name,
-					},
-				},
-				Spec: corev1.PodSpec{
-					ServiceAccountName: "steward",
-					Containers: []corev1.Container{
-						corev1.Container{
-							Name:  "redis",
-							Image: redisImage,
-							Args: []string{
-								"--save",
-								"",
-								"--appendonly",
-								"no",
-							},
-							Ports: []corev1.ContainerPort{
-								corev1.ContainerPort{
-									ContainerPort: 6379,
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-	if _, err := clientset.CoreV1().Services(namespace).Create(ctx, service, createOpts); err != nil {
-		if k8serr.IsAlreadyExists(err) {
-			klog.Warning("Argo CD redis service already exists")
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Created Argo CD redis service")
-	}
-	if _, err := clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, createOpts); err != nil {
-		if k8serr.IsAlreadyExists(err) {
-			klog.Warning("Argo CD redis deployment already exists")
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Created Argo CD redis deployment")
+				}),
+			).
+			WithTemplate(corev1ac.PodTemplateSpec().
+				WithLabels(map[string]string{
+					"app.kubernetes.io/name": name,
+				}).
+				WithSpec(corev1ac.PodSpec().
+					WithServiceAccountName("steward").
+					WithContainers(corev1ac.Container().
+						WithName("redis").
+						WithImage(redisImage).
+						WithArgs(
+							"--save",
+							"",
+							"--appendonly",
+							"no",
+						).
+						WithPorts(corev1ac.ContainerPort().
+							WithContainerPort(6379),
+						).
+						WithResources(tuning.resources()),
+					),
+				),
+			),
+		)
+	if err := applyObject(ctx, clientset.CoreV1().Services(namespace), service); err != nil {
+		return err
 	}
-	return nil
+	return applyObject(ctx, clientset.AppsV1().Deployments(namespace), deployment)
 }
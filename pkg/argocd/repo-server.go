@@ -5,16 +5,15 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
 
-	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	k8serr "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
 )
 
-func createRepoServerDeployment(ctx context.Context, clientset *kubernetes.Clientset, namespace, argoImage string) error {
-	name := "argocd-repo-server"
+func applyRepoServerDeployment(ctx context.Context, clientset kubernetes.Interface, namespace, argoImage string, tuning *ComponentTuning) error {
+	name := argoRepoServerName
 	labels := map[string]string{
 		"app.kubernetes.io/component": "server",
 		"app.kubernetes.io/name":      name,
@@ -23,151 +22,96 @@ func createRepoServerDeployment(ctx context.Context, clientset *kubernetes.Clien
 		labels[k] = v
 	}
 	annotations := argoAnnotations
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        name,
-			Namespace:   namespace,
-			Labels:      labels,
-			Annotations: argoAnnotations,
-		},
-		Spec: corev1.ServiceSpec{
-			Type: corev1.ServiceTypeClusterIP,
-			Selector: map[string]string{
+	service := corev1ac.Service(name, namespace).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithSpec(corev1ac.ServiceSpec().
+			WithType(corev1.ServiceTypeClusterIP).
+			WithSelector(map[string]string{
 				"app.kubernetes.io/name": name,
-			},
-			Ports: []corev1.ServicePort{{
-				Name: "server",
-				Port: 8081,
-				TargetPort: intstr.IntOrString{
-					IntVal: 8081,
-				}},
-			},
-		},
-	}
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        name,
-			Namespace:   namespace,
-			Labels:      labels,
-			Annotations: annotations,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
+			}).
+			WithPorts(corev1ac.ServicePort().
+				WithName("server").
+				WithPort(8081).
+				WithTargetPort(intstr.FromInt32(8081)),
+			),
+		)
+	deployment := appsv1ac.Deployment(name, namespace).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithSpec(appsv1ac.DeploymentSpec().
+			WithSelector(metav1ac.LabelSelector().
+				WithMatchLabels(map[string]string{
 					"app.kubernetes.io/name": name,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app.kubernetes.io/name": name,
-					},
-				},
-				Spec: corev1.PodSpec{
-					Volumes: []corev1.Volume{
-						{
-							Name: "ssh-known-hosts",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: argoSSHConfigMapName,
-									},
-								},
-							},
-						},
-						{
-							Name: "tls-certs",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: argoTLSConfigMapName,
-									},
-								},
-							},
-						},
-						{
-							Name: "gpg-keyring",
-							VolumeSource: corev1.VolumeSource{
-								EmptyDir: &corev1.EmptyDirVolumeSource{},
-							},
-						},
-					},
-					Containers: []corev1.Container{
-						{
-							Name:  "argocd-repo-server",
-							Image: argoImage,
-							Command: []string{
-								"uid_entrypoint.sh",
-								"argocd-repo-server",
-							},
-							Ports: []corev1.ContainerPort{
-								{
-									ContainerPort: 8081,
-								},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "ssh-known-hosts",
-									MountPath: "/app/config/ssh",
-								},
-								{
-									Name:      "tls-certs",
-									MountPath: "/app/config/tls",
-								},
-								{
-									Name:      "gpg-keyring",
-									MountPath: "/app/config/gpg/keys",
-								},
-							},
-							LivenessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/healthz?full=true",
-										Port: intstr.IntOrString{
-											IntVal: 8084,
-										},
-									},
-								},
-								InitialDelaySeconds: 30,
-								PeriodSeconds:       5,
-								FailureThreshold:    3,
-							},
-							ReadinessProbe: &corev1.Probe{
-								ProbeHandler: corev1.ProbeHandler{
-									HTTPGet: &corev1.HTTPGetAction{
-										Path: "/healthz",
-										Port: intstr.IntOrString{
-											IntVal: 8084,
-										},
-									},
-								},
-								InitialDelaySeconds: 5,
-								PeriodSeconds:       10,
-							},
-						},
-					},
-				},
-			},
-		},
-	}
+				}),
+			).
+			WithTemplate(corev1ac.PodTemplateSpec().
+				WithLabels(map[string]string{
+					"app.kubernetes.io/name": name,
+				}).
+				WithSpec(corev1ac.PodSpec().
+					WithVolumes(
+						corev1ac.Volume().
+							WithName("ssh-known-hosts").
+							WithConfigMap(corev1ac.ConfigMapVolumeSource().
+								WithName(argoSSHConfigMapName),
+							),
+						corev1ac.Volume().
+							WithName("tls-certs").
+							WithConfigMap(corev1ac.ConfigMapVolumeSource().
+								WithName(argoTLSConfigMapName),
+							),
+						corev1ac.Volume().
+							WithName("gpg-keyring").
+							WithEmptyDir(corev1ac.EmptyDirVolumeSource()),
+					).
+					WithContainers(corev1ac.Container().
+						WithName("argocd-repo-server").
+						WithImage(argoImage).
+						WithCommand(
+							"uid_entrypoint.sh",
+							"argocd-repo-server",
+						).
+						WithPorts(corev1ac.ContainerPort().
+							WithContainerPort(8081),
+						).
+						WithVolumeMounts(
+							corev1ac.VolumeMount().
+								WithName("ssh-known-hosts").
+								WithMountPath("/app/config/ssh"),
+							corev1ac.VolumeMount().
+								WithName("tls-certs").
+								WithMountPath("/app/config/tls"),
+							corev1ac.VolumeMount().
+								WithName("gpg-keyring").
+								WithMountPath("/app/config/gpg/keys"),
+						).
+						WithLivenessProbe(corev1ac.Probe().
+							WithHTTPGet(corev1ac.HTTPGetAction().
+								WithPath("/healthz?full=true").
+								WithPort(intstr.FromInt32(8084)),
+							).
+							WithInitialDelaySeconds(30).
+							WithPeriodSeconds(5).
+							WithFailureThreshold(3),
+						).
+						WithReadinessProbe(corev1ac.Probe().
+							WithHTTPGet(corev1ac.HTTPGetAction().
+								WithPath("/healthz").
+								WithPort(intstr.FromInt32(8084)),
+							).
+							WithInitialDelaySeconds(5).
+							WithPeriodSeconds(10),
+						).
+						WithResources(tuning.resources()),
+					),
+				),
+			),
+		)
+	deployment.Spec.Replicas = tuning.replicas()
 
-	if _, err := clientset.CoreV1().Services(namespace).Create(ctx, service, createOpts); err != nil {
-		if k8serr.IsAlreadyExists(err) {
-			klog.Warning("Argo CD repo-server service already exists")
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Created Argo CD repo-server service")
-	}
-	if _, err := clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, createOpts); err != nil {
-		if k8serr.IsAlreadyExists(err) {
-			klog.Warning("Argo CD repo-server deployment already exists")
-		} else {
-			return err
-		}
-	} else {
-		klog.Info("Created Argo CD repo-server deployment")
+	if err := applyObject(ctx, clientset.CoreV1().Services(namespace), service); err != nil {
+		return err
 	}
-	return nil
+	return applyObject(ctx, clientset.AppsV1().Deployments(namespace), deployment)
 }
@@ -0,0 +1,236 @@
+package argocd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	rootAppNamePrefix     = "root-"
+	defaultTargetRevision = "HEAD"
+)
+
+// RootApp defines an additional root app and its project.
+// Only the name is required, the other fields default to the values of the root apps created for a plain team name.
+// In JSON a root app is either an object or the team name as a string.
+type RootApp struct {
+	// Name of the team, the app is named `root-<name>` and its project `<name>`
+	Name string `json:"name"`
+	// Path of the app in the repository, defaults to `manifests/apps-<name>`
+	Path string `json:"path,omitempty"`
+	// TargetRevision of the app, defaults to `HEAD`
+	TargetRevision string `json:"targetRevision,omitempty"`
+	// RepoURL of the app, defaults to the catalog repository
+	RepoURL string `json:"repoURL,omitempty"`
+	// DestinationNamespace of the app, defaults to the Argo CD namespace
+	DestinationNamespace string `json:"destinationNamespace,omitempty"`
+	// SyncPolicy of the app, defaults to an automated sync with self heal and without pruning
+	SyncPolicy *SyncPolicy `json:"syncPolicy,omitempty"`
+	// Project restricts the sources and destinations of the project
+	Project *ProjectRestrictions `json:"project,omitempty"`
+}
+
+// SyncPolicy is the sync policy of an Argo CD application. Without automated the app is only synced manually.
+type SyncPolicy struct {
+	Automated   *AutomatedSync `json:"automated,omitempty"`
+	SyncOptions []string       `json:"syncOptions,omitempty"`
+}
+
+// AutomatedSync configures the automated sync of an Argo CD application
+type AutomatedSync struct {
+	Prune    bool `json:"prune"`
+	SelfHeal bool `json:"selfHeal"`
+}
+
+// ProjectRestrictions restrict the apps of an Argo CD project
+type ProjectRestrictions struct {
+	// SourceRepos defaults to the repository of the root app
+	SourceRepos []string `json:"sourceRepos,omitempty"`
+	// Destinations defaults to all namespaces of the local cluster
+	Destinations []ProjectDestination `json:"destinations,omitempty"`
+	// ClusterResourceWhitelist defaults to all cluster resources
+	ClusterResourceWhitelist []GroupKind `json:"clusterResourceWhitelist,omitempty"`
+}
+
+// ProjectDestination is a cluster and namespace the apps of a project may deploy to
+type ProjectDestination struct {
+	Server    string `json:"server,omitempty"`
+	Namespace string `json:"namespace"`
+}
+
+// GroupKind selects Kubernetes resources, `*` matches everything
+type GroupKind struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+}
+
+// defaultRootApp describes the root app created during the bootstrap, the app itself is named `root`
+var defaultRootApp = RootApp{Name: defaultArgoProjectName, Path: argoAppsPathPrefix}
+
+// UnmarshalJSON accepts a plain team name in addition to the object
+func (r *RootApp) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*r = RootApp{}
+		return json.Unmarshal(data, &r.Name)
+	}
+	type rootApp RootApp
+	return json.Unmarshal(data, (*rootApp)(r))
+}
+
+// Validate checks the name and the fields which end up in the names of other objects.
+// Names of the default project and root app are reserved.
+func (r RootApp) Validate() error {
+	var errs error
+	// The name is used for the project, the app `root-<name>` and as label value
+	msgs := validation.IsDNS1123Subdomain(r.Name)
+	if len(msgs) == 0 {
+		msgs = validation.IsDNS1123Subdomain(r.AppName())
+	}
+	msgs = append(msgs, validation.IsValidLabelValue(r.Name)...)
+	if len(msgs) > 0 {
+		errs = multierr.Append(errs, fmt.Errorf("invalid root app name %q: %s", r.Name, strings.Join(msgs, ", ")))
+	}
+	// The default project and root app created during the bootstrap must not be replaced
+	if r.Name == defaultArgoProjectName || r.AppName() == defaultArgoRootAppName {
+		errs = multierr.Append(errs, fmt.Errorf("invalid root app name %q: reserved for the default root app", r.Name))
+	}
+	if r.DestinationNamespace != "" {
+		if msgs := validation.IsDNS1123Label(r.DestinationNamespace); len(msgs) > 0 {
+			errs = multierr.Append(errs, fmt.Errorf("root app %s: invalid destination namespace %q: %s", r.Name, r.DestinationNamespace, strings.Join(msgs, ", ")))
+		}
+	}
+	if r.Path != "" && (path.IsAbs(r.Path) || strings.HasPrefix(path.Clean(r.Path), "..")) {
+		errs = multierr.Append(errs, fmt.Errorf("root app %s: path %q must be relative to the repository", r.Name, r.Path))
+	}
+	if r.Project != nil {
+		for _, d := range r.Project.Destinations {
+			if d.Namespace == "" {
+				errs = multierr.Append(errs, fmt.Errorf("root app %s: project destination without namespace", r.Name))
+			}
+		}
+	}
+	return errs
+}
+
+// AppName returns the name of the Argo CD application
+func (r RootApp) AppName() string {
+	return rootAppNamePrefix + r.Name
+}
+
+func (r RootApp) repoURL(cluster *api.Cluster) string {
+	return cmp.Or(r.RepoURL, *cluster.GitRepo.Url)
+}
+
+// appSpec returns the spec of the Argo CD application
+func (r RootApp) appSpec(cluster *api.Cluster, namespace string) map[string]interface{} {
+	// apps path for additional root apps is `manifests/apps-<team name>/`.
+	appsPath := cmp.Or(r.Path, argoAppsPathPrefix+"-"+r.Name)
+	syncPolicy := map[string]interface{}{
+		"automated": map[string]interface{}{
+			"prune":    false,
+			"selfHeal": true,
+		},
+	}
+	if r.SyncPolicy != nil {
+		syncPolicy = map[string]interface{}{}
+		if a := r.SyncPolicy.Automated; a != nil {
+			syncPolicy["automated"] = map[string]interface{}{
+				"prune":    a.Prune,
+				"selfHeal": a.SelfHeal,
+			}
+		}
+		if len(r.SyncPolicy.SyncOptions) > 0 {
+			syncPolicy["syncOptions"] = toInterfaces(r.SyncPolicy.SyncOptions)
+		}
+	}
+	return map[string]interface{}{
+		"project": r.Name,
+		"source": map[string]interface{}{
+			"repoURL":        r.repoURL(cluster),
+			"path":           strings.TrimSuffix(appsPath, "/") + "/",
+			"targetRevision": cmp.Or(r.TargetRevision, defaultTargetRevision),
+		},
+		"syncPolicy": syncPolicy,
+		"destination": map[string]interface{}{
+			"namespace": cmp.Or(r.DestinationNamespace, namespace),
+			"server":    localKubernetesAPI,
+		},
+	}
+}
+
+// projectSpec returns the spec of the Argo CD project
+func (r RootApp) projectSpec(cluster *api.Cluster) map[string]interface{} {
+	restrictions := ProjectRestrictions{}
+	if r.Project != nil {
+		restrictions = *r.Project
+	}
+	sourceRepos := restrictions.SourceRepos
+	if len(sourceRepos) == 0 {
+		sourceRepos = []string{r.repoURL(cluster)}
+	}
+	destinations := []interface{}{}
+	for _, d := range restrictions.Destinations {
+		destinations = append(destinations, map[string]interface{}{
+			"namespace": d.Namespace,
+			"server":    cmp.Or(d.Server, localKubernetesAPI),
+		})
+	}
+	if len(destinations) == 0 {
+		destinations = append(destinations, map[string]interface{}{
+			"namespace": "*",
+			"server":    localKubernetesAPI,
+		})
+	}
+	whitelist := []interface{}{}
+	for _, gk := range restrictions.ClusterResourceWhitelist {
+		whitelist = append(whitelist, map[string]interface{}{
+			"group": gk.Group,
+			"kind":  gk.Kind,
+		})
+	}
+	if len(whitelist) == 0 {
+		whitelist = append(whitelist, map[string]interface{}{
+			"group": "*",
+			"kind":  "*",
+		})
+	}
+	return map[string]interface{}{
+		"clusterResourceWhitelist": whitelist,
+		"destinations":             destinations,
+		"sourceRepos":              toInterfaces(sourceRepos),
+	}
+}
+
+// mergeRootApps returns the root apps of both lists, an app in overrides replaces the app with the same name in apps
+func mergeRootApps(apps, overrides []RootApp) []RootApp {
+	merged := append([]RootApp{}, apps...)
+	for _, o := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == o.Name {
+				merged[i] = o
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, o)
+		}
+	}
+	return merged
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package argocd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/projectsyn/lieutenant-api/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func catalogCluster() *api.Cluster {
+	cluster := &api.Cluster{}
+	cluster.GitRepo = &api.GitRepo{Url: ptr.To("ssh://git@example.com/catalog.git")}
+	return cluster
+}
+
+func TestRootAppUnmarshalJSON(t *testing.T) {
+	var apps []RootApp
+	require.NoError(t, json.Unmarshal([]byte(`["team-a", {"name": "team-b", "path": "teams/b", "syncPolicy": {"syncOptions": ["CreateNamespace=true"]}}]`), &apps))
+	assert.Equal(t, []RootApp{
+		{Name: "team-a"},
+		{Name: "team-b", Path: "teams/b", SyncPolicy: &SyncPolicy{SyncOptions: []string{"CreateNamespace=true"}}},
+	}, apps)
+
+	assert.Error(t, json.Unmarshal([]byte(`[42]`), &apps))
+}
+
+func TestRootAppValidate(t *testing.T) {
+	tcs := map[string]struct {
+		app   RootApp
+		valid bool
+	}{
+		"name":                {app: RootApp{Name: "team-a"}, valid: true},
+		"invalid name":        {app: RootApp{Name: "Team A"}},
+		"dotted name":         {app: RootApp{Name: "team.a"}, valid: true},
+		"long name":           {app: RootApp{Name: strings.Repeat("a", 64)}},
+		"empty name":          {app: RootApp{}},
+		"default project":     {app: RootApp{Name: defaultArgoProjectName}},
+		"root":                {app: RootApp{Name: defaultArgoRootAppName}, valid: true},
+		"relative path":       {app: RootApp{Name: "team-a", Path: "teams/a/"}, valid: true},
+		"absolute path":       {app: RootApp{Name: "team-a", Path: "/teams/a"}},
+		"path outside":        {app: RootApp{Name: "team-a", Path: "../teams/a"}},
+		"invalid namespace":   {app: RootApp{Name: "team-a", DestinationNamespace: "Team_A"}},
+		"destination":         {app: RootApp{Name: "team-a", Project: &ProjectRestrictions{Destinations: []ProjectDestination{{Namespace: "team-a-*"}}}}, valid: true},
+		"missing destination": {app: RootApp{Name: "team-a", Project: &ProjectRestrictions{Destinations: []ProjectDestination{{Server: localKubernetesAPI}}}}},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.app.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRootAppSpecDefaults(t *testing.T) {
+	app := RootApp{Name: "team-a"}
+	assert.Equal(t, "root-team-a", app.AppName())
+	assert.Equal(t, map[string]interface{}{
+		"project": "team-a",
+		"source": map[string]interface{}{
+			"repoURL":        "ssh://git@example.com/catalog.git",
+			"path":           "manifests/apps-team-a/",
+			"targetRevision": "HEAD",
+		},
+		"syncPolicy": map[string]interface{}{
+			"automated": map[string]interface{}{
+				"prune":    false,
+				"selfHeal": true,
+			},
+		},
+		"destination": map[string]interface{}{
+			"namespace": "syn",
+			"server":    localKubernetesAPI,
+		},
+	}, app.appSpec(catalogCluster(), "syn"))
+	assert.Equal(t, map[string]interface{}{
+		"clusterResourceWhitelist": []interface{}{map[string]interface{}{"group": "*", "kind": "*"}},
+		"destinations":             []interface{}{map[string]interface{}{"namespace": "*", "server": localKubernetesAPI}},
+		"sourceRepos":              []interface{}{"ssh://git@example.com/catalog.git"},
+	}, app.projectSpec(catalogCluster()))
+
+	assert.Equal(t, "manifests/apps/", defaultRootApp.appSpec(catalogCluster(), "syn")["source"].(map[string]interface{})["path"])
+}
+
+func TestRootAppSpec(t *testing.T) {
+	app := RootApp{
+		Name:                 "team-a",
+		Path:                 "teams/a",
+		TargetRevision:       "main",
+		RepoURL:              "https://git.example.com/team-a.git",
+		DestinationNamespace: "team-a",
+		SyncPolicy: &SyncPolicy{
+			Automated:   &AutomatedSync{Prune: true},
+			SyncOptions: []string{"CreateNamespace=true"},
+		},
+		Project: &ProjectRestrictions{
+			Destinations:             []ProjectDestination{{Namespace: "team-a-*"}},
+			ClusterResourceWhitelist: []GroupKind{{Group: "", Kind: "Namespace"}},
+		},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"project": "team-a",
+		"source": map[string]interface{}{
+			"repoURL":        "https://git.example.com/team-a.git",
+			"path":           "teams/a/",
+			"targetRevision": "main",
+		},
+		"syncPolicy": map[string]interface{}{
+			"automated": map[string]interface{}{
+				"prune":    true,
+				"selfHeal": false,
+			},
+			"syncOptions": []interface{}{"CreateNamespace=true"},
+		},
+		"destination": map[string]interface{}{
+			"namespace": "team-a",
+			"server":    localKubernetesAPI,
+		},
+	}, app.appSpec(catalogCluster(), "syn"))
+	assert.Equal(t, map[string]interface{}{
+		"clusterResourceWhitelist": []interface{}{map[string]interface{}{"group": "", "kind": "Namespace"}},
+		"destinations":             []interface{}{map[string]interface{}{"namespace": "team-a-*", "server": localKubernetesAPI}},
+		"sourceRepos":              []interface{}{"https://git.example.com/team-a.git"},
+	}, app.projectSpec(catalogCluster()))
+
+	manual := RootApp{Name: "team-a", SyncPolicy: &SyncPolicy{}}
+	assert.Equal(t, map[string]interface{}{}, manual.appSpec(catalogCluster(), "syn")["syncPolicy"])
+}
+
+func TestMergeRootApps(t *testing.T) {
+	merged := mergeRootApps(
+		[]RootApp{{Name: "team-a"}, {Name: "team-b"}},
+		[]RootApp{{Name: "team-b", TargetRevision: "main"}, {Name: "team-c"}},
+	)
+	assert.Equal(t, []RootApp{{Name: "team-a"}, {Name: "team-b", TargetRevision: "main"}, {Name: "team-c"}}, merged)
+}
+
+func TestReadAdditionalRootAppsConfigMap(t *testing.T) {
+	tcs := map[string]struct {
+		teams    string
+		expected []RootApp
+		err      string
+	}{
+		"names": {
+			teams:    `["team-a", "team-b"]`,
+			expected: []RootApp{{Name: "team-a"}, {Name: "team-b"}},
+		},
+		"mixed": {
+			teams:    `["team-a", {"name": "team-b", "targetRevision": "main"}]`,
+			expected: []RootApp{{Name: "team-a"}, {Name: "team-b", TargetRevision: "main"}},
+		},
+		"not a list": {
+			teams: `{"name": "team-a"}`,
+			err:   "unmarshalling additional root apps ConfigMap contents",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "additional-root-apps", Namespace: "syn"},
+				Data:       map[string]string{additionalRootAppsConfigKey: tc.teams},
+			})
+			apps, found, err := readAdditionalRootAppsConfigMap(t.Context(), client, "syn", "additional-root-apps")
+			assert.True(t, found)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, apps)
+		})
+	}
+}
@@ -7,8 +7,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
-	"fmt"
-	"net/url"
+	"sync"
 	"time"
 
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
@@ -17,10 +16,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/crypto/ssh"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/applyconfigurations/core/v1"
+
+	"github.com/projectsyn/steward/pkg/events"
 )
 
 // CreateArgoSecret creates a new secret for Argo CD
@@ -30,11 +31,7 @@ func CreateArgoSecret(ctx context.Context, clientset kubernetes.Interface, names
 	if len(password) > 72 {
 		password = password[:72]
 	}
-	pwHashBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	mtime := time.Now().Format(time.RFC3339)
-	if err != nil {
-		return err
-	}
 	clusterSecret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, argoClusterSecretName, metav1.GetOptions{})
 	if err == nil {
 		// If the operator-managed cluster secret exists, the password is updated there instead
@@ -56,7 +53,8 @@ func CreateArgoSecret(ctx context.Context, clientset kubernetes.Interface, names
 		if err != nil {
 			return err
 		}
-		klog.Info("Argo CD Cluster secret updated with new password")
+		klog.FromContext(ctx).Info("Updated Argo CD admin password", "kind", "Secret", "name", argoClusterSecretName)
+		events.Normal(events.ReasonArgoCDPasswordRotated, "Updated the Argo CD admin password in secret %s", argoClusterSecretName)
 		return nil
 	}
 
@@ -66,19 +64,18 @@ func CreateArgoSecret(ctx context.Context, clientset kubernetes.Interface, names
 	}
 
 	argoSecret := corev1.Secret(argoSecretName, namespace)
-	infoMsg := "Created new Argo CD secret"
+	infoMsg := "Created Argo CD secret"
 	secretApplyOpts := applyOpts
-	if err == nil {
-		currentPwHash := secret.Data["admin.password"]
-		err = bcrypt.CompareHashAndPassword(currentPwHash, []byte(password))
-		if err == nil {
+	rotated := err == nil
+	if rotated {
+		if verifiedPasswordHash.matches(secret.Data["admin.password"], password) {
 			return nil
 		}
 		argoSecret, err = corev1.ExtractSecret(secret, fieldManager)
 		if err != nil {
 			return err
 		}
-		infoMsg = "Argo CD secret updated with new password"
+		infoMsg = "Updated Argo CD admin password"
 		secretApplyOpts = metav1.ApplyOptions{
 			FieldManager: fieldManager,
 			// We need to force the update to ensure the password
@@ -87,6 +84,10 @@ func CreateArgoSecret(ctx context.Context, clientset kubernetes.Interface, names
 		}
 	}
 
+	pwHashBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
 	argoSecret.WithData(
 		map[string][]byte{
 			"admin.password":      pwHashBytes,
@@ -97,62 +98,77 @@ func CreateArgoSecret(ctx context.Context, clientset kubernetes.Interface, names
 	if err != nil {
 		return err
 	}
-	klog.Info(infoMsg)
+	klog.FromContext(ctx).Info(infoMsg, "kind", "Secret", "name", argoSecretName)
+	if rotated {
+		events.Normal(events.ReasonArgoCDPasswordRotated, "Updated the Argo CD admin password in secret %s", argoSecretName)
+	}
 	return nil
 }
 
-func createRepoSecret(ctx context.Context, cluster *api.Cluster, clientset kubernetes.Interface, namespace string) error {
-	if cluster == nil {
-		return fmt.Errorf("no cluster passed to createRepoSecret")
-	}
-	if cluster.GitRepo == nil || cluster.GitRepo.Url == nil {
-		return fmt.Errorf("no git repo information received from API for cluster '%s'", cluster.Id)
+// passwordHashCache remembers the last password hash verified against its password.
+// bcrypt is deliberately expensive and the password rarely changes, so it's not verified again on every sync.
+type passwordHashCache struct {
+	mu       sync.Mutex
+	hash     []byte
+	password string
+}
+
+var verifiedPasswordHash = &passwordHashCache{}
+
+func (c *passwordHashCache) matches(hash []byte, password string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.password == password && bytes.Equal(c.hash, hash) {
+		return true
 	}
-	gitURL, err := url.Parse(*cluster.GitRepo.Url)
-	if err != nil {
-		return err
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil {
+		return false
 	}
+	c.hash, c.password = bytes.Clone(hash), password
+	return true
+}
 
-	repoSecret := corev1.Secret(argoRepoSecretName, namespace)
-	repoSecret.WithLabels(
-		map[string]string{
-			"argocd.argoproj.io/secret-type": "repository",
-		},
-	)
-	repoSecret.WithData(
-		map[string][]byte{
-			"type": []byte("git"),
-			"url":  []byte(gitURL.String()),
-		},
-	)
-
-	_, err = clientset.CoreV1().Secrets(namespace).Apply(ctx, repoSecret, applyOpts)
+func createRepoSecret(ctx context.Context, cluster *api.Cluster, clientset kubernetes.Interface, namespace string) error {
+	gitURL, err := catalogURL(cluster)
 	if err != nil {
 		return err
 	}
-	klog.Infof("Created new Repo secret")
 
 	// Patch credential secret to also map to the URL
 	sshSecretObj, err := clientset.CoreV1().Secrets(namespace).Get(ctx, argoSSHSecretName, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
-	sshSecret, err := corev1.ExtractSecret(sshSecretObj, fieldManager)
-	if err != nil {
-		return err
-	}
-	if sshSecret.Data == nil {
-		sshSecret.Data = make(map[string][]byte)
-	}
-	sshSecret.Data["url"] = []byte(gitURL.String())
+	if manager := takenOverBy(sshSecretObj); manager != "" {
+		klog.FromContext(ctx).V(1).Info("Argo CD object is managed by someone else, skipping", "kind", "Secret", "name", argoSSHSecretName, "manager", manager)
+	} else {
+		sshSecret, err := corev1.ExtractSecret(sshSecretObj, fieldManager)
+		if err != nil {
+			return err
+		}
+		if sshSecret.Data == nil {
+			sshSecret.Data = make(map[string][]byte)
+		}
+		sshSecret.Data["url"] = []byte(gitURL)
 
-	_, err = clientset.CoreV1().Secrets(namespace).Apply(ctx, sshSecret, applyOpts)
-	if err != nil {
-		return err
+		// The URL is forced, a catalog migration must not fail on a conflict with whoever set it before
+		_, err = clientset.CoreV1().Secrets(namespace).Apply(ctx, sshSecret, forceApplyOpts)
+		if err != nil {
+			return err
+		}
+		klog.FromContext(ctx).Info("Updated SSH secret with the repository URL", "kind", "Secret", "name", argoSSHSecretName)
 	}
 
-	klog.Infof("Updated SSH secret")
-	return nil
+	// The repository secret is applied last, its URL marks a completed catalog migration
+	return applyObject(ctx, clientset.CoreV1().Secrets(namespace), corev1.Secret(argoRepoSecretName, namespace).
+		WithLabels(map[string]string{
+			"argocd.argoproj.io/secret-type": "repository",
+		}).
+		WithData(map[string][]byte{
+			"type": []byte("git"),
+			"url":  []byte(gitURL),
+		}),
+	)
 }
 
 // CreateSSHSecret creates a new SSH key if it doesn't exist already and returns the public key
@@ -165,13 +181,13 @@ func CreateSSHSecret(ctx context.Context, clientset kubernetes.Interface, namesp
 		return "", err
 	}
 
-	klog.Info("No SSH secret found, generate new key")
+	klog.FromContext(ctx).Info("No SSH secret found, generating new key", "kind", "Secret", "name", argoSSHSecretName)
 
 	publicKey, privateKey, err := generateSSHKey()
 	if err != nil {
 		return "", err
 	}
-	klog.Infof("Public key: %v", publicKey)
+	klog.FromContext(ctx).Info("Generated SSH key", "publicKey", publicKey)
 	sshSecret := corev1.Secret(argoSSHSecretName, namespace)
 	sshSecret.WithLabels(
 		map[string]string{
@@ -189,6 +205,7 @@ func CreateSSHSecret(ctx context.Context, clientset kubernetes.Interface, namesp
 	if err != nil {
 		return publicKey, err
 	}
+	events.Normal(events.ReasonSSHKeyGenerated, "Generated a new SSH deploy key in secret %s", argoSSHSecretName)
 	return publicKey, nil
 }
 
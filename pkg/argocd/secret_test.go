@@ -171,6 +171,23 @@ func TestCreateArgoClusterSecretUpdate(t *testing.T) {
 	}
 }
 
+func TestPasswordHashCache(t *testing.T) {
+	fooHash, err := bcrypt.GenerateFromPassword([]byte("foo"), bcrypt.MinCost)
+	require.NoError(t, err)
+	barHash, err := bcrypt.GenerateFromPassword([]byte("bar"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	cache := &passwordHashCache{}
+	assert.False(t, cache.matches(barHash, "foo"))
+	assert.Empty(t, cache.hash)
+	assert.True(t, cache.matches(fooHash, "foo"))
+	assert.Equal(t, fooHash, cache.hash)
+	assert.True(t, cache.matches(fooHash, "foo"))
+	assert.False(t, cache.matches(fooHash, "bar"))
+	assert.True(t, cache.matches(barHash, "bar"))
+	assert.False(t, cache.matches([]byte("invalid"), "bar"))
+}
+
 func makeCluster(t *testing.T, cid, repoUrl string) *api.Cluster {
 
 	apiId := api.Id(cid)
@@ -0,0 +1,86 @@
+package argocd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"go.uber.org/multierr"
+
+	corev1 "k8s.io/api/core/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// Tuning customizes the Argo CD components steward bootstraps and reconciles.
+// Components taken over by Argo CD or the operator aren't tuned, the catalog manages them.
+type Tuning struct {
+	// Config holds additional settings of the argocd-cm ConfigMap
+	Config map[string]string `json:"config,omitempty"`
+	// Server, RepoServer, ApplicationController and Redis tune the respective component
+	Server                *ComponentTuning `json:"server,omitempty"`
+	RepoServer            *ComponentTuning `json:"repoServer,omitempty"`
+	ApplicationController *ComponentTuning `json:"applicationController,omitempty"`
+	Redis                 *ComponentTuning `json:"redis,omitempty"`
+}
+
+// ComponentTuning sets the replicas and the resources of an Argo CD component, unset fields keep the defaults
+type ComponentTuning struct {
+	// Replicas of the component, only the server and the repo server can be scaled
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Resources of the component's container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// Validate rejects settings of argocd-cm set by steward and replicas of components which can't be scaled
+func (t Tuning) Validate() error {
+	var errs error
+	for _, key := range slices.Sorted(maps.Keys(t.Config)) {
+		if _, ok := argoConfigDefaults[key]; ok {
+			errs = multierr.Append(errs, fmt.Errorf("config: %s is set by steward", key))
+		}
+	}
+	for _, c := range []struct {
+		name     string
+		tuning   *ComponentTuning
+		scalable bool
+	}{
+		{"server", t.Server, true},
+		{"repoServer", t.RepoServer, true},
+		{"applicationController", t.ApplicationController, false},
+		{"redis", t.Redis, false},
+	} {
+		if c.tuning == nil || c.tuning.Replicas == nil {
+			continue
+		}
+		switch {
+		case !c.scalable:
+			errs = multierr.Append(errs, fmt.Errorf("%s: replicas can't be changed", c.name))
+		case *c.tuning.Replicas < 1:
+			errs = multierr.Append(errs, fmt.Errorf("%s: replicas must be at least 1, got %d", c.name, *c.tuning.Replicas))
+		}
+	}
+	return errs
+}
+
+// replicas returns the replicas of the component, or nil to keep the default
+func (c *ComponentTuning) replicas() *int32 {
+	if c == nil {
+		return nil
+	}
+	return c.Replicas
+}
+
+// resources returns the apply configuration of the component's resources, or nil to leave them unset
+func (c *ComponentTuning) resources() *corev1ac.ResourceRequirementsApplyConfiguration {
+	if c == nil || c.Resources == nil {
+		return nil
+	}
+	resources := corev1ac.ResourceRequirements()
+	if c.Resources.Limits != nil {
+		resources.WithLimits(c.Resources.Limits)
+	}
+	if c.Resources.Requests != nil {
+		resources.WithRequests(c.Resources.Requests)
+	}
+	return resources
+}
@@ -0,0 +1,83 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func TestTuningValidate(t *testing.T) {
+	tcs := map[string]struct {
+		tuning   Tuning
+		expected []string
+	}{
+		"empty": {},
+		"valid": {
+			tuning: Tuning{
+				Config:                map[string]string{"timeout.reconciliation": "300s"},
+				Server:                &ComponentTuning{Replicas: ptr.To[int32](2)},
+				RepoServer:            &ComponentTuning{Replicas: ptr.To[int32](1)},
+				ApplicationController: &ComponentTuning{Resources: &corev1.ResourceRequirements{}},
+			},
+		},
+		"invalid": {
+			tuning: Tuning{
+				Config:                map[string]string{"application.resourceTrackingMethod": "annotation"},
+				Server:                &ComponentTuning{Replicas: ptr.To[int32](0)},
+				ApplicationController: &ComponentTuning{Replicas: ptr.To[int32](2)},
+				Redis:                 &ComponentTuning{Replicas: ptr.To[int32](1)},
+			},
+			expected: []string{
+				"config: application.resourceTrackingMethod is set by steward",
+				"server: replicas must be at least 1, got 0",
+				"applicationController: replicas can't be changed",
+				"redis: replicas can't be changed",
+			},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.tuning.Validate()
+			if len(tc.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range tc.expected {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestReconcileArgoComponentsTuning(t *testing.T) {
+	client := fake.NewClientset()
+	memory := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v1", "redis:v1", Tuning{
+		Config:     map[string]string{"timeout.reconciliation": "300s"},
+		Server:     &ComponentTuning{Replicas: ptr.To[int32](2)},
+		RepoServer: &ComponentTuning{Resources: &corev1.ResourceRequirements{Limits: memory}},
+	}))
+
+	cm, err := client.CoreV1().ConfigMaps("syn").Get(t.Context(), argoConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "300s", cm.Data["timeout.reconciliation"])
+	assert.Equal(t, "label", cm.Data["application.resourceTrackingMethod"])
+	assert.Equal(t, ptr.To[int32](2), getDeployment(t, client, argoServerName).Spec.Replicas)
+	assert.Equal(t, memory, getDeployment(t, client, argoRepoServerName).Spec.Template.Spec.Containers[0].Resources.Limits)
+
+	// Without the tuning steward releases the fields again
+	require.NoError(t, reconcileArgoComponents(t.Context(), client, "syn", "argocd:v1", "redis:v1", Tuning{}))
+	cm, err = client.CoreV1().ConfigMaps("syn").Get(t.Context(), argoConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, cm.Data, "timeout.reconciliation")
+	assert.Equal(t, "label", cm.Data["application.resourceTrackingMethod"])
+	assert.Nil(t, getDeployment(t, client, argoServerName).Spec.Replicas)
+	assert.Empty(t, getDeployment(t, client, argoRepoServerName).Spec.Template.Spec.Containers[0].Resources.Limits)
+}
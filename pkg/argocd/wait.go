@@ -0,0 +1,85 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultRolloutTimeout is used if no timeout is given to wait for a rollout
+	DefaultRolloutTimeout = 5 * time.Minute
+	rolloutPollInterval   = 2 * time.Second
+)
+
+// waitForRollout waits until all Argo CD deployments and the application controller statefulset run their current spec.
+// The timeout applies to the whole rollout, not to each component.
+func waitForRollout(ctx context.Context, clientset kubernetes.Interface, namespace string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultRolloutTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, name := range []string{argoRedisName, argoRepoServerName, argoServerName} {
+		if err := waitFor(ctx, timeout, "Deployment", name, func(ctx context.Context) (bool, error) {
+			d, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return deploymentRolledOut(d), nil
+		}); err != nil {
+			return err
+		}
+	}
+	return waitFor(ctx, timeout, "StatefulSet", argoApplicationControllerName, func(ctx context.Context) (bool, error) {
+		s, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, argoApplicationControllerName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return statefulSetRolledOut(s), nil
+	})
+}
+
+// waitFor polls the condition until it's true or the timeout expired
+func waitFor(ctx context.Context, timeout time.Duration, kind, name string, condition wait.ConditionWithContextFunc) error {
+	klog.FromContext(ctx).V(1).Info("Waiting for Argo CD object", "kind", kind, "name", name, "timeout", timeout)
+	// The first poll runs even if the context is already done
+	err := ctx.Err()
+	if err == nil {
+		err = wait.PollUntilContextTimeout(ctx, rolloutPollInterval, timeout, true, condition)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s not ready within %s: %w", kind, name, timeout, err)
+	}
+	return nil
+}
+
+// deploymentRolledOut returns true if all replicas of the deployment run its current spec and are available
+func deploymentRolledOut(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+// statefulSetRolledOut returns true if all replicas of the statefulset run its current revision and are ready
+func statefulSetRolledOut(s *appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	return s.Status.ObservedGeneration >= s.Generation &&
+		s.Status.UpdatedReplicas == replicas &&
+		s.Status.ReadyReplicas == replicas &&
+		s.Status.CurrentRevision == s.Status.UpdateRevision
+}
@@ -0,0 +1,127 @@
+package argocd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestDeploymentRolledOut(t *testing.T) {
+	tcs := map[string]struct {
+		replicas *int32
+		status   appsv1.DeploymentStatus
+		expected bool
+	}{
+		"rolled out": {
+			replicas: ptr.To[int32](2),
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			expected: true,
+		},
+		"default replicas": {
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			expected: true,
+		},
+		"spec not observed": {
+			status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		},
+		"old replica remaining": {
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+		},
+		"not available": {
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			d := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: tc.replicas},
+				Status:     tc.status,
+			}
+			assert.Equal(t, tc.expected, deploymentRolledOut(d))
+		})
+	}
+}
+
+func TestStatefulSetRolledOut(t *testing.T) {
+	tcs := map[string]struct {
+		status   appsv1.StatefulSetStatus
+		expected bool
+	}{
+		"rolled out": {
+			status:   appsv1.StatefulSetStatus{ObservedGeneration: 2, UpdatedReplicas: 1, ReadyReplicas: 1, CurrentRevision: "b", UpdateRevision: "b"},
+			expected: true,
+		},
+		"spec not observed": {
+			status: appsv1.StatefulSetStatus{ObservedGeneration: 1, UpdatedReplicas: 1, ReadyReplicas: 1, CurrentRevision: "b", UpdateRevision: "b"},
+		},
+		"not ready": {
+			status: appsv1.StatefulSetStatus{ObservedGeneration: 2, UpdatedReplicas: 1, CurrentRevision: "b", UpdateRevision: "b"},
+		},
+		"revision pending": {
+			status: appsv1.StatefulSetStatus{ObservedGeneration: 2, UpdatedReplicas: 1, ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "b"},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			s := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Status:     tc.status,
+			}
+			assert.Equal(t, tc.expected, statefulSetRolledOut(s))
+		})
+	}
+}
+
+func rolledOutComponents() *fake.Clientset {
+	objects := []*appsv1.Deployment{}
+	for _, name := range []string{argoRedisName, argoRepoServerName, argoServerName} {
+		objects = append(objects, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "syn"},
+			Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		})
+	}
+	return fake.NewClientset(objects[0], objects[1], objects[2], &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: argoApplicationControllerName, Namespace: "syn"},
+		Status:     appsv1.StatefulSetStatus{UpdatedReplicas: 1, ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "a"},
+	})
+}
+
+func TestWaitForRollout(t *testing.T) {
+	client := rolledOutComponents()
+	require.NoError(t, waitForRollout(t.Context(), client, "syn", time.Second))
+}
+
+func TestWaitForRolloutTimeout(t *testing.T) {
+	client := rolledOutComponents()
+	s, err := client.AppsV1().StatefulSets("syn").Get(t.Context(), argoApplicationControllerName, metav1.GetOptions{})
+	require.NoError(t, err)
+	s.Status.UpdateRevision = "b"
+	_, err = client.AppsV1().StatefulSets("syn").UpdateStatus(t.Context(), s, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	err = waitForRollout(t.Context(), client, "syn", 10*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "StatefulSet "+argoApplicationControllerName+" not ready")
+}
+
+func TestWaitForRolloutSharesTimeout(t *testing.T) {
+	client := rolledOutComponents()
+	// Each component is ready within the timeout, but not all of them together
+	client.PrependReactor("get", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(40 * time.Millisecond)
+		return false, nil, nil
+	})
+
+	err := waitForRollout(t.Context(), client, "syn", 100*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not ready within 100ms")
+}
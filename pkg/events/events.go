@@ -0,0 +1,100 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// Reasons of the events emitted by steward
+const (
+	ReasonArgoCDBootstrapped              = "ArgoCDBootstrapped"
+	ReasonArgoCDBootstrapFailed           = "ArgoCDBootstrapFailed"
+	ReasonArgoCDUpgraded                  = "ArgoCDUpgraded"
+	ReasonArgoCDUpgradeFailed             = "ArgoCDUpgradeFailed"
+	ReasonCatalogMigrated                 = "CatalogMigrated"
+	ReasonCatalogMigrationFailed          = "CatalogMigrationFailed"
+	ReasonArgoCDPasswordRotated           = "ArgoCDPasswordRotated"
+	ReasonSSHKeyGenerated                 = "SSHKeyGenerated"
+	ReasonArgoCDOperatorRestart           = "ArgoCDOperatorRestarted"
+	ReasonSyncFailed                      = "SyncFailed"
+	ReasonLieutenantRequestError          = "LieutenantRequestFailed"
+	ReasonStewardConfigInvalid            = "StewardConfigInvalid"
+	ReasonAdditionalRootAppRemovalPending = "AdditionalRootAppRemovalPending"
+	ReasonAdditionalRootAppRemoved        = "AdditionalRootAppRemoved"
+	ReasonAdditionalRootAppInvalid        = "AdditionalRootAppInvalid"
+)
+
+const component = "steward"
+
+var (
+	mu       sync.RWMutex
+	recorder record.EventRecorder
+	object   *corev1.ObjectReference
+)
+
+// Setup starts recording events on steward's deployment.
+// Events aren't recorded until Setup is called, so packages can emit them unconditionally.
+// The returned function stops the recording and flushes the pending events.
+func Setup(ctx context.Context, client kubernetes.Interface, namespace, deploymentName string) func() {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	SetRecorder(broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component}), deploymentReference(ctx, client, namespace, deploymentName))
+	return func() {
+		SetRecorder(nil, nil)
+		broadcaster.Shutdown()
+	}
+}
+
+// SetRecorder replaces the recorder and the object the events are recorded on.
+// A nil recorder disables the events.
+func SetRecorder(r record.EventRecorder, ref *corev1.ObjectReference) {
+	mu.Lock()
+	defer mu.Unlock()
+	recorder, object = r, ref
+}
+
+// Normal records an event about an expected change
+func Normal(reason, messageFmt string, args ...interface{}) {
+	event(corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// Warning records an event about a failure
+func Warning(reason, messageFmt string, args ...interface{}) {
+	event(corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+func event(eventType, reason, messageFmt string, args ...interface{}) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(object, eventType, reason, messageFmt, args...)
+}
+
+// deploymentReference references steward's deployment.
+// The UID is needed for `kubectl describe` to list the events, it's left empty if the deployment can't be read.
+func deploymentReference(ctx context.Context, client kubernetes.Interface, namespace, name string) *corev1.ObjectReference {
+	ref := &corev1.ObjectReference{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+		Namespace:  namespace,
+		Name:       name,
+	}
+	deployment, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Unable to get deployment to record events on", "namespace", namespace, "kind", "Deployment", "name", name)
+		return ref
+	}
+	ref.UID = deployment.UID
+	return ref
+}
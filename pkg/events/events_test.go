@@ -0,0 +1,47 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventsWithoutRecorder(t *testing.T) {
+	SetRecorder(nil, nil)
+	assert.NotPanics(t, func() {
+		Normal(ReasonSSHKeyGenerated, "generated %s", "key")
+		Warning(ReasonSyncFailed, "failed")
+	})
+}
+
+func TestEvents(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	SetRecorder(rec, &corev1.ObjectReference{Kind: "Deployment", Namespace: "syn", Name: "steward"})
+	t.Cleanup(func() { SetRecorder(nil, nil) })
+
+	Normal(ReasonArgoCDBootstrapped, "Bootstrapped Argo CD with image %s", "argocd:v3")
+	Warning(ReasonSyncFailed, "Sync failed: %v", "boom")
+
+	require.Len(t, rec.Events, 2)
+	assert.Equal(t, "Normal ArgoCDBootstrapped Bootstrapped Argo CD with image argocd:v3", <-rec.Events)
+	assert.Equal(t, "Warning SyncFailed Sync failed: boom", <-rec.Events)
+}
+
+func TestDeploymentReference(t *testing.T) {
+	client := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "steward", Namespace: "syn", UID: "1234"},
+	})
+
+	ref := deploymentReference(t.Context(), client, "syn", "steward")
+	assert.Equal(t, &corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "syn", Name: "steward", UID: "1234"}, ref)
+
+	ref = deploymentReference(t.Context(), client, "syn", "missing")
+	assert.Empty(t, ref.UID)
+	assert.Equal(t, "missing", ref.Name)
+}
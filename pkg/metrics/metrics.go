@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "steward"
+
+var (
+	// Registry holds all steward metrics and the Go runtime and process metrics
+	Registry = prometheus.NewRegistry()
+
+	// LieutenantRequests counts the requests to the Lieutenant API by operation and HTTP status code.
+	// Requests which failed without a response have the code `error`.
+	LieutenantRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lieutenant_requests_total",
+		Help:      "Requests to the Lieutenant API by operation and HTTP status code.",
+	}, []string{"operation", "code"})
+
+	// LieutenantRequestDuration observes the latency of the requests to the Lieutenant API by operation
+	LieutenantRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lieutenant_request_duration_seconds",
+		Help:      "Latency of the requests to the Lieutenant API by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// FactCollectionDuration observes the time each fact provider takes
+	FactCollectionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fact_collection_duration_seconds",
+		Help:      "Time taken to collect the dynamic facts by fact provider.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	// FactCollectionErrors counts the failed runs of each fact provider
+	FactCollectionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fact_collection_errors_total",
+		Help:      "Failed fact collections by fact provider.",
+	}, []string{"provider"})
+
+	// ArgoCDBootstraps counts the Argo CD bootstrap runs by result
+	ArgoCDBootstraps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "argocd_bootstraps_total",
+		Help:      "Argo CD bootstrap runs by result (success, failure).",
+	}, []string{"result"})
+
+	// ArgoCDUpgrades counts the Argo CD image upgrades by result
+	ArgoCDUpgrades = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "argocd_upgrades_total",
+		Help:      "Argo CD image upgrades by result (success, failure).",
+	}, []string{"result"})
+
+	// CatalogMigrations counts the migrations to a new catalog repository URL
+	CatalogMigrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "catalog_migrations_total",
+		Help:      "Migrations to a new catalog repository URL by result (success, failure).",
+	}, []string{"result"})
+
+	// ArgoCDOperatorRestarts counts the restarts of the Argo CD operator to resolve its deadlock
+	ArgoCDOperatorRestarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "argocd_operator_restarts_total",
+		Help:      "Restarts of the Argo CD operator to resolve its deadlock.",
+	})
+
+	// Syncs counts the sync runs by result
+	Syncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "syncs_total",
+		Help:      "Sync runs by result (success, failure).",
+	}, []string{"result"})
+
+	// LastSuccessfulSync is the Unix time of the last successful sync
+	LastSuccessfulSync = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync.",
+	})
+
+	// Leader is 1 while the instance is leading and runs the syncs
+	Leader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "leader",
+		Help:      "1 while this instance is the leader and runs the syncs, 0 otherwise.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		LieutenantRequests,
+		LieutenantRequestDuration,
+		FactCollectionDuration,
+		FactCollectionErrors,
+		ArgoCDBootstraps,
+		ArgoCDUpgrades,
+		CatalogMigrations,
+		ArgoCDOperatorRestarts,
+		Syncs,
+		LastSuccessfulSync,
+		Leader,
+	)
+}
+
+// Handler serves the metrics of the Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Result returns the result label value for the error
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// StatusCode returns the code label value for a Lieutenant API response
+func StatusCode(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
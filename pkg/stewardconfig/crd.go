@@ -0,0 +1,70 @@
+package stewardconfig
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apixv1client "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+)
+
+// CRDEstablishedTimeout limits the wait for the StewardConfig CRD to be served
+const CRDEstablishedTimeout = 30 * time.Second
+
+//go:embed crd.yaml
+var crdManifest []byte
+
+// CRD returns the CustomResourceDefinition of the StewardConfig resource
+func CRD() (*apixv1.CustomResourceDefinition, error) {
+	crd := &apixv1.CustomResourceDefinition{}
+	if err := yaml.UnmarshalStrict(crdManifest, crd); err != nil {
+		return nil, fmt.Errorf("invalid StewardConfig CRD: %w", err)
+	}
+	return crd, nil
+}
+
+// InstallCRD applies the StewardConfig CRD with server-side apply
+func InstallCRD(ctx context.Context, client apixv1client.CustomResourceDefinitionsGetter) error {
+	crd, err := CRD()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(crd)
+	if err != nil {
+		return err
+	}
+	if _, err := client.CustomResourceDefinitions().Patch(ctx, crd.Name, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: fieldManager,
+		Force:        ptr.To(true),
+	}); err != nil {
+		return fmt.Errorf("unable to install the StewardConfig CRD: %w", err)
+	}
+	klog.FromContext(ctx).V(1).Info("Installed CRD", "kind", "CustomResourceDefinition", "name", crd.Name)
+	return nil
+}
+
+// WaitForCRD waits until the StewardConfig CRD is established, so the StewardConfig resources are served
+func WaitForCRD(ctx context.Context, client apixv1client.CustomResourceDefinitionsGetter, timeout time.Duration) error {
+	name := GVR.Resource + "." + Group
+	if err := wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		crd, err := client.CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return argocd.CRDEstablished(crd), nil
+	}); err != nil {
+		return fmt.Errorf("StewardConfig CRD not established within %s: %w", timeout, err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package stewardconfig
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+)
+
+const (
+	// ConditionReady reports whether the configuration is applied
+	ConditionReady = "Ready"
+	// ReasonApplied means the configuration is valid and in use
+	ReasonApplied = "Applied"
+	// ReasonInvalid means the configuration is invalid and steward keeps its previous settings
+	ReasonInvalid = "Invalid"
+
+	fieldManager = "syn.tools/steward"
+)
+
+// Settings are the settings of steward which can be changed with a StewardConfig
+type Settings struct {
+	ArgoCDImage          string
+	RedisImage           string
+	AdditionalRootApps   []argocd.RootApp
+	PruneRootApps        bool
+	FactProviders        []string
+	DisabledProviders    []string
+	FactProviderTimeout  time.Duration
+	CapacityFactsByRole  bool
+	CRDFacts             bool
+	ResyncInterval       time.Duration
+	ForceSyncInterval    time.Duration
+	ArgoCDUpgrade        bool
+	ArgoCDUpgradeTimeout time.Duration
+	ArgoCDTuning         argocd.Tuning
+}
+
+// Apply returns the settings with the fields set in the spec replaced
+func (s StewardConfigSpec) Apply(settings Settings) Settings {
+	if s.Images != nil {
+		settings.ArgoCDImage = cmp.Or(s.Images.ArgoCD, settings.ArgoCDImage)
+		settings.RedisImage = cmp.Or(s.Images.Redis, settings.RedisImage)
+	}
+	if s.AdditionalRootApps != nil {
+		settings.AdditionalRootApps = s.AdditionalRootApps
+	}
+	if f := s.Facts; f != nil {
+		if f.EnabledProviders != nil {
+			settings.FactProviders = f.EnabledProviders
+		}
+		if f.DisabledProviders != nil {
+			settings.DisabledProviders = f.DisabledProviders
+		}
+		setDuration(&settings.FactProviderTimeout, f.ProviderTimeout)
+		setBool(&settings.CapacityFactsByRole, f.CapacityByRole)
+		setBool(&settings.CRDFacts, f.CRDs)
+	}
+	if s.Sync != nil {
+		setDuration(&settings.ResyncInterval, s.Sync.ResyncInterval)
+		setDuration(&settings.ForceSyncInterval, s.Sync.ForceSyncInterval)
+	}
+	if s.ArgoCD != nil {
+		setBool(&settings.ArgoCDUpgrade, s.ArgoCD.Upgrade)
+		setDuration(&settings.ArgoCDUpgradeTimeout, s.ArgoCD.UpgradeTimeout)
+		setBool(&settings.PruneRootApps, s.ArgoCD.PruneAdditionalRootApps)
+		tuning := &settings.ArgoCDTuning
+		if s.ArgoCD.Config != nil {
+			tuning.Config = s.ArgoCD.Config
+		}
+		tuning.Server = cmp.Or(s.ArgoCD.Server, tuning.Server)
+		tuning.RepoServer = cmp.Or(s.ArgoCD.RepoServer, tuning.RepoServer)
+		tuning.ApplicationController = cmp.Or(s.ArgoCD.ApplicationController, tuning.ApplicationController)
+		tuning.Redis = cmp.Or(s.ArgoCD.Redis, tuning.Redis)
+	}
+	return settings
+}
+
+// Validate checks the values of the spec which can't be validated by the CRD schema
+func (s StewardConfigSpec) Validate() error {
+	var errs error
+	if s.Images != nil {
+		errs = multierr.Append(errs, validateImage("images.argocd", s.Images.ArgoCD))
+		errs = multierr.Append(errs, validateImage("images.redis", s.Images.Redis))
+	}
+	for _, app := range s.AdditionalRootApps {
+		if err := app.Validate(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("additionalRootApps: %w", err))
+		}
+	}
+	if s.Facts != nil {
+		errs = multierr.Append(errs, validateDuration("facts.providerTimeout", s.Facts.ProviderTimeout))
+	}
+	if s.Sync != nil {
+		errs = multierr.Append(errs, validateDuration("sync.resyncInterval", s.Sync.ResyncInterval))
+		errs = multierr.Append(errs, validateDuration("sync.forceSyncInterval", s.Sync.ForceSyncInterval))
+	}
+	if s.ArgoCD != nil {
+		errs = multierr.Append(errs, validateDuration("argocd.upgradeTimeout", s.ArgoCD.UpgradeTimeout))
+		for _, err := range multierr.Errors(s.ArgoCD.Tuning.Validate()) {
+			errs = multierr.Append(errs, fmt.Errorf("argocd.%w", err))
+		}
+	}
+	return errs
+}
+
+// Get returns the StewardConfig, or nil if it or its CRD doesn't exist
+func Get(ctx context.Context, client dynamic.Interface, namespace, name string) (*StewardConfig, error) {
+	obj, err := client.Resource(GVR).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to fetch the steward config: %w", err)
+	}
+	cfg := &StewardConfig{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse the steward config: %w", err)
+	}
+	return cfg, nil
+}
+
+// UpdateStatus sets the Ready condition of the StewardConfig for its current generation.
+// A nil err marks the configuration as applied, otherwise as invalid.
+func UpdateStatus(ctx context.Context, client dynamic.Interface, cfg *StewardConfig, err error) error {
+	condition := metav1.Condition{
+		Type:               ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonApplied,
+		Message:            "The configuration is applied",
+		ObservedGeneration: cfg.Generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonInvalid
+		condition.Message = "Keeping the previous settings: " + err.Error()
+	}
+	conditions := slices.Clone(cfg.Status.Conditions)
+	meta.SetStatusCondition(&conditions, condition)
+	if cfg.Status.ObservedGeneration == cfg.Generation && slices.Equal(cfg.Status.Conditions, conditions) {
+		return nil
+	}
+
+	status, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&StewardConfigStatus{
+		ObservedGeneration: cfg.Generation,
+		Conditions:         conditions,
+	})
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Group + "/" + Version,
+		"kind":       Kind,
+		"metadata": map[string]interface{}{
+			"name":      cfg.Name,
+			"namespace": cfg.Namespace,
+		},
+		"status": status,
+	}}
+	if _, err := client.Resource(GVR).Namespace(cfg.Namespace).ApplyStatus(ctx, cfg.Name, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true}); err != nil {
+		return fmt.Errorf("unable to update the steward config status: %w", err)
+	}
+	cfg.Status.ObservedGeneration = cfg.Generation
+	cfg.Status.Conditions = conditions
+	return nil
+}
+
+func validateImage(field, image string) error {
+	if strings.ContainsAny(image, " \t\n") {
+		return fmt.Errorf("%s: invalid image %q", field, image)
+	}
+	return nil
+}
+
+func validateDuration(field string, d *metav1.Duration) error {
+	if d != nil && d.Duration <= 0 {
+		return fmt.Errorf("%s: must be positive, got %s", field, d.Duration)
+	}
+	return nil
+}
+
+func setDuration(target *time.Duration, d *metav1.Duration) {
+	if d != nil {
+		*target = d.Duration
+	}
+}
+
+func setBool(target *bool, b *bool) {
+	if b != nil {
+		*target = *b
+	}
+}
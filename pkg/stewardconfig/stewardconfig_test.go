@@ -0,0 +1,275 @@
+package stewardconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apixv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apixfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+)
+
+func duration(d time.Duration) *metav1.Duration {
+	return &metav1.Duration{Duration: d}
+}
+
+func TestApply(t *testing.T) {
+	flags := Settings{
+		ArgoCDImage:         "argocd:v1",
+		RedisImage:          "redis:v1",
+		FactProviders:       []string{"nodes"},
+		FactProviderTimeout: time.Second,
+		CRDFacts:            true,
+		ResyncInterval:      5 * time.Minute,
+		ForceSyncInterval:   time.Hour,
+	}
+	tcs := map[string]struct {
+		spec     StewardConfigSpec
+		expected Settings
+	}{
+		"empty": {
+			expected: flags,
+		},
+		"all": {
+			spec: StewardConfigSpec{
+				Images:             &Images{ArgoCD: "argocd:v2", Redis: "redis:v2"},
+				AdditionalRootApps: []argocd.RootApp{{Name: "team-a"}},
+				Facts: &Facts{
+					EnabledProviders:  []string{},
+					DisabledProviders: []string{"apis"},
+					ProviderTimeout:   duration(time.Minute),
+					CapacityByRole:    ptr.To(true),
+					CRDs:              ptr.To(false),
+				},
+				Sync: &Sync{ResyncInterval: duration(time.Minute), ForceSyncInterval: duration(2 * time.Hour)},
+				ArgoCD: &ArgoCD{
+					Upgrade:                 ptr.To(true),
+					UpgradeTimeout:          duration(10 * time.Minute),
+					PruneAdditionalRootApps: ptr.To(true),
+					Tuning: argocd.Tuning{
+						Config: map[string]string{"timeout.reconciliation": "300s"},
+						Server: &argocd.ComponentTuning{Replicas: ptr.To[int32](2)},
+					},
+				},
+			},
+			expected: Settings{
+				ArgoCDImage:          "argocd:v2",
+				RedisImage:           "redis:v2",
+				AdditionalRootApps:   []argocd.RootApp{{Name: "team-a"}},
+				PruneRootApps:        true,
+				FactProviders:        []string{},
+				DisabledProviders:    []string{"apis"},
+				FactProviderTimeout:  time.Minute,
+				CapacityFactsByRole:  true,
+				CRDFacts:             false,
+				ResyncInterval:       time.Minute,
+				ForceSyncInterval:    2 * time.Hour,
+				ArgoCDUpgrade:        true,
+				ArgoCDUpgradeTimeout: 10 * time.Minute,
+				ArgoCDTuning: argocd.Tuning{
+					Config: map[string]string{"timeout.reconciliation": "300s"},
+					Server: &argocd.ComponentTuning{Replicas: ptr.To[int32](2)},
+				},
+			},
+		},
+		"partial": {
+			spec: StewardConfigSpec{
+				Images: &Images{Redis: "redis:v2"},
+				Sync:   &Sync{ForceSyncInterval: duration(2 * time.Hour)},
+			},
+			expected: Settings{
+				ArgoCDImage:         "argocd:v1",
+				RedisImage:          "redis:v2",
+				FactProviders:       []string{"nodes"},
+				FactProviderTimeout: time.Second,
+				CRDFacts:            true,
+				ResyncInterval:      5 * time.Minute,
+				ForceSyncInterval:   2 * time.Hour,
+			},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.spec.Apply(flags))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tcs := map[string]struct {
+		spec     StewardConfigSpec
+		expected []string
+	}{
+		"empty": {},
+		"valid": {
+			spec: StewardConfigSpec{
+				Images:             &Images{ArgoCD: "quay.io/argoproj/argocd:v3.1.9"},
+				AdditionalRootApps: []argocd.RootApp{{Name: "team-a"}},
+				Sync:               &Sync{ResyncInterval: duration(time.Minute)},
+			},
+		},
+		"invalid": {
+			spec: StewardConfigSpec{
+				Images:             &Images{Redis: "redis latest"},
+				AdditionalRootApps: []argocd.RootApp{{Name: "Team_A"}},
+				Facts:              &Facts{ProviderTimeout: duration(0)},
+				Sync:               &Sync{ForceSyncInterval: duration(-time.Minute)},
+				ArgoCD: &ArgoCD{
+					UpgradeTimeout: duration(0),
+					Tuning: argocd.Tuning{
+						Config: map[string]string{"configManagementPlugins": ""},
+						Redis:  &argocd.ComponentTuning{Replicas: ptr.To[int32](2)},
+					},
+				},
+			},
+			expected: []string{
+				`images.redis: invalid image "redis latest"`,
+				`additionalRootApps: invalid root app name "Team_A"`,
+				"facts.providerTimeout: must be positive, got 0s",
+				"sync.forceSyncInterval: must be positive, got -1m0s",
+				"argocd.upgradeTimeout: must be positive, got 0s",
+				"argocd.config: configManagementPlugins is set by steward",
+				"argocd.redis: replicas can't be changed",
+			},
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			err := tc.spec.Validate()
+			if len(tc.expected) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range tc.expected {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestCRD(t *testing.T) {
+	crd, err := CRD()
+	require.NoError(t, err)
+	assert.Equal(t, GVR.Resource+"."+Group, crd.Name)
+	assert.Equal(t, Kind, crd.Spec.Names.Kind)
+	require.Len(t, crd.Spec.Versions, 1)
+	assert.Equal(t, Version, crd.Spec.Versions[0].Name)
+	assert.NotNil(t, crd.Spec.Versions[0].Subresources.Status)
+}
+
+func TestWaitForCRD(t *testing.T) {
+	crd, err := CRD()
+	require.NoError(t, err)
+	client := apixfake.NewSimpleClientset(crd) //nolint:staticcheck
+	err = WaitForCRD(t.Context(), client.ApiextensionsV1(), 10*time.Millisecond)
+	assert.ErrorContains(t, err, "StewardConfig CRD not established")
+
+	crd.Status.Conditions = []apixv1.CustomResourceDefinitionCondition{
+		{Type: apixv1.NamesAccepted, Status: apixv1.ConditionTrue},
+		{Type: apixv1.Established, Status: apixv1.ConditionTrue},
+	}
+	_, err = client.ApiextensionsV1().CustomResourceDefinitions().UpdateStatus(t.Context(), crd, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, WaitForCRD(t.Context(), client.ApiextensionsV1(), time.Second))
+}
+
+func newConfig(generation int64, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": Group + "/" + Version,
+		"kind":       Kind,
+		"metadata": map[string]interface{}{
+			"name":       "steward",
+			"namespace":  "syn",
+			"generation": generation,
+		},
+		"spec": spec,
+	}}
+}
+
+// newClient returns a fake client which applies the status of StewardConfigs.
+// The fake dynamic client doesn't support apply patches.
+func newClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{GVR: Kind + "List"}, objects...)
+	client.PrependReactor("patch", GVR.Resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType || patch.GetSubresource() != "status" {
+			return false, nil, nil
+		}
+		applied := &unstructured.Unstructured{}
+		if err := applied.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		current, err := client.Tracker().Get(GVR, patch.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		obj := current.(*unstructured.Unstructured).DeepCopy()
+		obj.Object["status"] = applied.Object["status"]
+		return true, obj, client.Tracker().Update(GVR, obj, patch.GetNamespace())
+	})
+	return client
+}
+
+func TestGetAndUpdateStatus(t *testing.T) {
+	client := newClient(newConfig(2, map[string]interface{}{
+		"images": map[string]interface{}{"argocd": "argocd:v2"},
+		"sync":   map[string]interface{}{"resyncInterval": "1m"},
+		"argocd": map[string]interface{}{
+			"config": map[string]interface{}{"timeout.reconciliation": "300s"},
+			"repoServer": map[string]interface{}{
+				"replicas":  int64(2),
+				"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+			},
+		},
+		"additionalRootApps": []interface{}{
+			"team-a",
+			map[string]interface{}{"name": "team-b", "targetRevision": "main"},
+		},
+	}))
+
+	cfg, err := Get(t.Context(), client, "syn", "steward")
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Equal(t, "argocd:v2", cfg.Spec.Images.ArgoCD)
+	assert.Equal(t, time.Minute, cfg.Spec.Sync.ResyncInterval.Duration)
+	assert.Equal(t, []argocd.RootApp{{Name: "team-a"}, {Name: "team-b", TargetRevision: "main"}}, cfg.Spec.AdditionalRootApps)
+	assert.Equal(t, map[string]string{"timeout.reconciliation": "300s"}, cfg.Spec.ArgoCD.Config)
+	require.NotNil(t, cfg.Spec.ArgoCD.RepoServer)
+	assert.Equal(t, ptr.To[int32](2), cfg.Spec.ArgoCD.RepoServer.Replicas)
+	assert.Equal(t, "1Gi", cfg.Spec.ArgoCD.RepoServer.Resources.Limits.Memory().String())
+
+	require.NoError(t, UpdateStatus(t.Context(), client, cfg, nil))
+	cfg, err = Get(t.Context(), client, "syn", "steward")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cfg.Status.ObservedGeneration)
+	require.Len(t, cfg.Status.Conditions, 1)
+	assert.Equal(t, metav1.ConditionTrue, cfg.Status.Conditions[0].Status)
+	assert.Equal(t, ReasonApplied, cfg.Status.Conditions[0].Reason)
+
+	require.NoError(t, UpdateStatus(t.Context(), client, cfg, assert.AnError))
+	cfg, err = Get(t.Context(), client, "syn", "steward")
+	require.NoError(t, err)
+	require.Len(t, cfg.Status.Conditions, 1)
+	assert.Equal(t, metav1.ConditionFalse, cfg.Status.Conditions[0].Status)
+	assert.Equal(t, ReasonInvalid, cfg.Status.Conditions[0].Reason)
+	assert.Contains(t, cfg.Status.Conditions[0].Message, assert.AnError.Error())
+}
+
+func TestGetMissing(t *testing.T) {
+	client := newClient()
+	cfg, err := Get(t.Context(), client, "syn", "steward")
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+}
@@ -0,0 +1,88 @@
+package stewardconfig
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/projectsyn/steward/pkg/argocd"
+)
+
+const (
+	// Group and Version of the StewardConfig resource
+	Group   = "syn.tools"
+	Version = "v1alpha1"
+	Kind    = "StewardConfig"
+)
+
+// GVR identifies the StewardConfig resource
+var GVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: "stewardconfigs"}
+
+// StewardConfig changes the settings of steward at runtime.
+// Fields which aren't set keep the values of the command line flags.
+type StewardConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   StewardConfigSpec   `json:"spec,omitempty"`
+	Status StewardConfigStatus `json:"status,omitempty"`
+}
+
+// StewardConfigSpec holds the settings of steward
+type StewardConfigSpec struct {
+	// Images of the Argo CD components
+	Images *Images `json:"images,omitempty"`
+	// AdditionalRootApps are created in addition to the ones listed in the additional root apps ConfigMap.
+	// Each entry is either a team name or an object with the name and the options of the root app.
+	AdditionalRootApps []argocd.RootApp `json:"additionalRootApps,omitempty"`
+	// Facts configures the fact collection
+	Facts *Facts `json:"facts,omitempty"`
+	// Sync configures the intervals of the syncs with Lieutenant
+	Sync *Sync `json:"sync,omitempty"`
+	// ArgoCD configures the reconciliation of Argo CD
+	ArgoCD *ArgoCD `json:"argocd,omitempty"`
+}
+
+// Images of the Argo CD components
+type Images struct {
+	ArgoCD string `json:"argocd,omitempty"`
+	Redis  string `json:"redis,omitempty"`
+}
+
+// Facts configures the fact collection
+type Facts struct {
+	// EnabledProviders limits the fact providers to run
+	EnabledProviders []string `json:"enabledProviders,omitempty"`
+	// DisabledProviders lists fact providers which are never run
+	DisabledProviders []string `json:"disabledProviders,omitempty"`
+	// ProviderTimeout limits the time each fact provider may take
+	ProviderTimeout *metav1.Duration `json:"providerTimeout,omitempty"`
+	// CapacityByRole adds the capacity per node role to the capacity fact
+	CapacityByRole *bool `json:"capacityByRole,omitempty"`
+	// CRDs adds the names of all installed CRDs to the dynamic facts
+	CRDs *bool `json:"crds,omitempty"`
+}
+
+// Sync configures the intervals of the syncs with Lieutenant
+type Sync struct {
+	ResyncInterval    *metav1.Duration `json:"resyncInterval,omitempty"`
+	ForceSyncInterval *metav1.Duration `json:"forceSyncInterval,omitempty"`
+}
+
+// ArgoCD configures the reconciliation of Argo CD and tunes its components
+type ArgoCD struct {
+	// Upgrade rolls out changed images to an already bootstrapped Argo CD
+	Upgrade *bool `json:"upgrade,omitempty"`
+	// UpgradeTimeout limits the time to wait for the rollout of an upgrade
+	UpgradeTimeout *metav1.Duration `json:"upgradeTimeout,omitempty"`
+	// PruneAdditionalRootApps deletes the root apps and projects of teams which are no longer listed
+	PruneAdditionalRootApps *bool `json:"pruneAdditionalRootApps,omitempty"`
+	// Tuning holds additional settings of argocd-cm and the replicas and resources of the components
+	argocd.Tuning `json:",inline"`
+}
+
+// StewardConfigStatus reports whether steward applied the configuration
+type StewardConfigStatus struct {
+	// ObservedGeneration is the generation of the spec steward last evaluated
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -17,6 +18,10 @@ func main() {
 	additionalFactsConfigMap := flag.String("additional-facts-config-map", "additional-facts", "configmap containing additional facts to be added to the dynamic facts")
 	ocpOAuthRouteNamespace := flag.String("ocp-oauth-route-namespace", "openshift-authentication", "Namespace for the OpenShift OAuth route")
 	ocpOAuthRouteName := flag.String("ocp-oauth-route-name", "oauth-openshift", "Name of the OpenShift OAuth route")
+	enabledProviders := flag.String("fact-providers", "", "comma-separated list of fact providers to run, runs all providers if empty. Available providers: "+strings.Join(facts.Providers(), ", "))
+	capacityByRole := flag.Bool("capacity-facts-by-role", false, "add the capacity per node role to the capacity fact")
+	crdFacts := flag.Bool("crd-facts", false, "add the names of all installed CRDs to the dynamic facts")
+	providerTimeout := flag.Duration("fact-provider-timeout", facts.DefaultProviderTimeout, "maximum time a single fact provider may take")
 
 	flag.Parse()
 
@@ -35,6 +40,19 @@ func main() {
 
 		AdditionalFactsConfigMapNamespace: *ns,
 		AdditionalFactsConfigMapName:      *additionalFactsConfigMap,
+
+		ArgoCDNamespace: *ns,
+
+		CapacityByRole: *capacityByRole,
+		CollectCRDs:    *crdFacts,
+
+		ProviderTimeout: *providerTimeout,
+	}
+	if *enabledProviders != "" {
+		c.EnabledProviders = strings.Split(*enabledProviders, ",")
+	}
+	if err := c.Validate(); err != nil {
+		panic(err)
 	}
 
 	fcts, err := c.FetchDynamicFacts(context.Background())